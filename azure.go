@@ -0,0 +1,170 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+	"maragu.dev/errors"
+)
+
+// AzureOptions for using Azure OpenAI deployments instead of the OpenAI API.
+// Exactly one of Key and TokenProvider must be set.
+type AzureOptions struct {
+	// Endpoint of the Azure OpenAI resource, like "https://example.openai.azure.com".
+	Endpoint string
+	// APIVersion of the Azure OpenAI API. Defaults to "2024-10-21".
+	APIVersion string
+	// Deployments maps model names, like "gpt-4o-mini", to the names of their deployments.
+	// Models that aren't in the map are assumed to be deployed with the model name.
+	Deployments map[string]string
+	// Key for the api-key header.
+	Key string
+	// TokenProvider for Microsoft Entra ID bearer tokens. Tokens are cached and refreshed before they expire.
+	TokenProvider AzureTokenProvider
+}
+
+// AzureTokenProvider returns a bearer token for the "https://cognitiveservices.azure.com/.default" scope
+// and when it expires. If expiresAt is zero, a new token is requested for every request.
+type AzureTokenProvider func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+// azureTokenRefreshMargin is how long before expiry a token is refreshed.
+const azureTokenRefreshMargin = 5 * time.Minute
+
+// azureDeploymentPaths are the API paths that are routed to a deployment, based on the model in the request body.
+var azureDeploymentPaths = []string{"/openai/chat/completions", "/openai/embeddings"}
+
+// azure rewrites requests for Azure OpenAI, adding the API version, deployment path, and authentication.
+type azure struct {
+	apiVersion    string
+	deployments   map[string]string
+	expiresAt     time.Time
+	key           string
+	lock          sync.Mutex
+	now           func() time.Time
+	token         string
+	tokenProvider AzureTokenProvider
+}
+
+func newAzure(opts AzureOptions) *azure {
+	if opts.Endpoint == "" {
+		panic("azure endpoint must be set")
+	}
+	if (opts.Key == "") == (opts.TokenProvider == nil) {
+		panic("exactly one of azure key and token provider must be set")
+	}
+	if opts.APIVersion == "" {
+		opts.APIVersion = "2024-10-21"
+	}
+
+	return &azure{
+		apiVersion:    opts.APIVersion,
+		deployments:   opts.Deployments,
+		key:           opts.Key,
+		now:           time.Now,
+		tokenProvider: opts.TokenProvider,
+	}
+}
+
+// baseURL for the OpenAI SDK, given the resource endpoint.
+func azureBaseURL(endpoint string) string {
+	return strings.TrimSuffix(endpoint, "/") + "/openai/"
+}
+
+func (a *azure) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	q := req.URL.Query()
+	q.Set("api-version", a.apiVersion)
+	req.URL.RawQuery = q.Encode()
+
+	for _, p := range azureDeploymentPaths {
+		if !strings.HasSuffix(req.URL.Path, p) {
+			continue
+		}
+
+		deployment, err := a.deployment(req)
+		if err != nil {
+			return nil, err
+		}
+		// The deployment is escaped in the raw path, so names with characters like / and ? stay one path segment
+		escaped := req.URL.EscapedPath()
+		i := strings.LastIndex(req.URL.Path, "/openai/")
+		j := strings.LastIndex(escaped, "/openai/")
+		req.URL.Path = req.URL.Path[:i] + "/openai/deployments/" + deployment + req.URL.Path[i+len("/openai"):]
+		req.URL.RawPath = escaped[:j] + "/openai/deployments/" + url.PathEscape(deployment) + escaped[j+len("/openai"):]
+		break
+	}
+
+	// The SDK sets the OpenAI API key as a bearer token, which Azure doesn't use
+	req.Header.Del("Authorization")
+
+	if c, ok := credentialsFromContext(req.Context()); ok && c.Key != "" {
+		req.Header.Set("api-key", c.Key)
+		return next(req)
+	}
+
+	if a.key != "" {
+		req.Header.Set("api-key", a.key)
+		return next(req)
+	}
+
+	token, err := a.getToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return next(req)
+}
+
+// deployment for the model in the request body.
+func (a *azure) deployment(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", errors.New("no request body to get the model from")
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "error reading request body")
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var v struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", errors.Wrap(err, "error getting model from request body")
+	}
+	if v.Model == "" {
+		return "", errors.New("no model in request body")
+	}
+
+	if deployment, ok := a.deployments[v.Model]; ok {
+		return deployment, nil
+	}
+	return v.Model, nil
+}
+
+// getToken from the cache, or from the token provider if it's missing or about to expire.
+func (a *azure) getToken(ctx context.Context) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token != "" && a.now().Add(azureTokenRefreshMargin).Before(a.expiresAt) {
+		return a.token, nil
+	}
+
+	token, expiresAt, err := a.tokenProvider(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "error getting azure token")
+	}
+	a.token = token
+	a.expiresAt = expiresAt
+	return token, nil
+}
@@ -0,0 +1,128 @@
+package openai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_Azure(t *testing.T) {
+	t.Run("can chat-complete with a deployment and an API key", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/openai/deployments/my-gpt/chat/completions", r.URL.Path)
+			is.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+			is.Equal(t, "azure-key", r.Header.Get("api-key"))
+			is.Equal(t, "", r.Header.Get("Authorization"))
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"}}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{
+				Endpoint:    s.URL,
+				Deployments: map[string]string{string(openai.ChatCompleteModelGPT4oMini): "my-gpt"},
+				Key:         "azure-key",
+			},
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var output string
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			output += part.Text()
+		}
+		is.Equal(t, "Hello!", output)
+	})
+
+	t.Run("escapes deployment names in the path", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/openai/deployments/my%20gpt%2Fv2%3F%23/embeddings", r.URL.EscapedPath())
+			is.Equal(t, "2024-10-21", r.URL.Query().Get("api-version"))
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{
+				Endpoint:    s.URL,
+				Deployments: map[string]string{string(openai.EmbedModelTextEmbedding3Small): "my gpt/v2?#"},
+				Key:         "azure-key",
+			},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+	})
+
+	t.Run("can embed with the model name as deployment and a cached Entra ID token", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/openai/deployments/text-embedding-3-small/embeddings", r.URL.Path)
+			is.Equal(t, "2025-01-01-preview", r.URL.Query().Get("api-version"))
+			is.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		var calls int
+		c := openai.NewClient(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{
+				Endpoint:   s.URL + "/",
+				APIVersion: "2025-01-01-preview",
+				TokenProvider: func(ctx context.Context) (string, time.Time, error) {
+					calls++
+					return "token", time.Now().Add(time.Hour), nil
+				},
+			},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		for range 2 {
+			_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+			is.NotError(t, err)
+		}
+		is.Equal(t, 1, calls)
+	})
+
+	t.Run("refreshes Entra ID tokens that are about to expire", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		var calls int
+		c := openai.NewClient(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{
+				Endpoint: s.URL,
+				TokenProvider: func(ctx context.Context) (string, time.Time, error) {
+					calls++
+					return "token", time.Now().Add(time.Minute), nil
+				},
+			},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		for range 2 {
+			_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+			is.NotError(t, err)
+		}
+		is.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,446 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/openai/openai-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+)
+
+// Batcher runs chat completions and embeddings through the Batch API,
+// which is cheaper than real-time requests but finishes within a completion window instead of immediately.
+type Batcher struct {
+	Client       openai.Client
+	log          *slog.Logger
+	pollInterval time.Duration
+	tracer       trace.Tracer
+}
+
+type NewBatcherOptions struct {
+	// PollInterval for [Batcher.Wait]. Defaults to 30 seconds.
+	PollInterval time.Duration
+}
+
+func (c *Client) NewBatcher(opts NewBatcherOptions) *Batcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 30 * time.Second
+	}
+
+	return &Batcher{
+		Client:       c.Client,
+		log:          c.log,
+		pollInterval: opts.PollInterval,
+		tracer:       c.tracer,
+	}
+}
+
+type BatchStatus string
+
+const (
+	BatchStatusValidating = BatchStatus(openai.BatchStatusValidating)
+	BatchStatusFailed     = BatchStatus(openai.BatchStatusFailed)
+	BatchStatusInProgress = BatchStatus(openai.BatchStatusInProgress)
+	BatchStatusFinalizing = BatchStatus(openai.BatchStatusFinalizing)
+	BatchStatusCompleted  = BatchStatus(openai.BatchStatusCompleted)
+	BatchStatusExpired    = BatchStatus(openai.BatchStatusExpired)
+	BatchStatusCancelling = BatchStatus(openai.BatchStatusCancelling)
+	BatchStatusCancelled  = BatchStatus(openai.BatchStatusCancelled)
+)
+
+// Batch is the state of a batch job.
+type Batch struct {
+	ID       string
+	Status   BatchStatus
+	Endpoint string
+	// Errors are validation errors for the whole batch, not for individual requests.
+	Errors       []string
+	InputFileID  string
+	OutputFileID string
+	ErrorFileID  string
+	Total        int
+	Completed    int
+	Failed       int
+}
+
+// Done is true if the batch has reached a terminal status.
+func (b Batch) Done() bool {
+	switch b.Status {
+	case BatchStatusCompleted, BatchStatusFailed, BatchStatusExpired, BatchStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// ChatCompleteBatchRequest is a chat completion request in a batch, identified by a custom ID unique within the batch.
+type ChatCompleteBatchRequest struct {
+	CustomID string
+	Request  gai.ChatCompleteRequest
+}
+
+// EmbedBatchRequest is an embedding request in a batch, identified by a custom ID unique within the batch.
+type EmbedBatchRequest struct {
+	CustomID string
+	Request  gai.EmbedRequest
+}
+
+// ChatCompleteBatchResult is the result of a single [ChatCompleteBatchRequest].
+// Err is set if the request failed, in which case Response is empty.
+type ChatCompleteBatchResult struct {
+	Response gai.ChatCompleteResponse
+	Err      error
+}
+
+// EmbedBatchResult is the result of a single [EmbedBatchRequest].
+// Err is set if the request failed, in which case Response is empty.
+type EmbedBatchResult struct {
+	Response gai.EmbedResponse[float64]
+	Err      error
+}
+
+// batchInputLine is a line in a batch input file.
+type batchInputLine struct {
+	CustomID string `json:"custom_id"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Body     any    `json:"body"`
+}
+
+// batchOutputLine is a line in a batch output or error file.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		RequestID  string          `json:"request_id"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// WriteBatchInput writes the requests as a JSONL batch input file to w,
+// using the same request conversion as [ChatCompleter.ChatComplete].
+func (c *ChatCompleter) WriteBatchInput(w io.Writer, reqs []ChatCompleteBatchRequest) error {
+	lines := make([]batchInputLine, len(reqs))
+	for i, r := range reqs {
+		params, err := c.NewParams(r.Request)
+		if err != nil {
+			return errors.Wrap(err, "error converting request %v", r.CustomID)
+		}
+		lines[i] = batchInputLine{CustomID: r.CustomID, Body: params}
+	}
+	return writeBatchInput(w, openai.BatchNewParamsEndpointV1ChatCompletions, lines)
+}
+
+// WriteBatchInput writes the requests as a JSONL batch input file to w,
+// using the same request conversion as [Embedder.Embed].
+func (e *Embedder) WriteBatchInput(w io.Writer, reqs []EmbedBatchRequest) error {
+	lines := make([]batchInputLine, len(reqs))
+	for i, r := range reqs {
+		lines[i] = batchInputLine{CustomID: r.CustomID, Body: e.newParams(gai.ReadAllString(r.Request.Input))}
+	}
+	return writeBatchInput(w, openai.BatchNewParamsEndpointV1Embeddings, lines)
+}
+
+func writeBatchInput(w io.Writer, endpoint openai.BatchNewParamsEndpoint, lines []batchInputLine) error {
+	seen := map[string]bool{}
+	enc := json.NewEncoder(w)
+	for _, line := range lines {
+		if line.CustomID == "" {
+			return errors.New("custom ID must not be empty")
+		}
+		if seen[line.CustomID] {
+			return errors.Newf("duplicate custom ID %v", line.CustomID)
+		}
+		seen[line.CustomID] = true
+
+		line.Method = "POST"
+		line.URL = string(endpoint)
+		if err := enc.Encode(line); err != nil {
+			return errors.Wrap(err, "error encoding batch line %v", line.CustomID)
+		}
+	}
+	return nil
+}
+
+// CreateChatCompleteBatch uploads the requests as a batch input file and creates a batch for them with the given chat completer.
+func (b *Batcher) CreateChatCompleteBatch(ctx context.Context, cc *ChatCompleter, reqs []ChatCompleteBatchRequest) (Batch, error) {
+	var buf bytes.Buffer
+	if err := cc.WriteBatchInput(&buf, reqs); err != nil {
+		return Batch{}, err
+	}
+	return b.create(ctx, openai.BatchNewParamsEndpointV1ChatCompletions, &buf, len(reqs))
+}
+
+// CreateEmbedBatch uploads the requests as a batch input file and creates a batch for them with the given embedder.
+func (b *Batcher) CreateEmbedBatch(ctx context.Context, e *Embedder, reqs []EmbedBatchRequest) (Batch, error) {
+	var buf bytes.Buffer
+	if err := e.WriteBatchInput(&buf, reqs); err != nil {
+		return Batch{}, err
+	}
+	return b.create(ctx, openai.BatchNewParamsEndpointV1Embeddings, &buf, len(reqs))
+}
+
+func (b *Batcher) create(ctx context.Context, endpoint openai.BatchNewParamsEndpoint, input io.Reader, count int) (Batch, error) {
+	ctx, span := b.tracer.Start(ctx, "openai.batch_create",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.batch_endpoint", string(endpoint)),
+			attribute.Int("ai.batch_request_count", count),
+		),
+	)
+	defer span.End()
+
+	file, err := b.Client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.File(input, "batch.jsonl", "application/jsonl"),
+		Purpose: openai.FilePurposeBatch,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "batch input upload failed")
+		return Batch{}, errors.Wrap(toAPIError(err), "error uploading batch input")
+	}
+
+	batch, err := b.Client.Batches.New(ctx, openai.BatchNewParams{
+		CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+		Endpoint:         endpoint,
+		InputFileID:      file.ID,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "batch creation failed")
+		return Batch{}, errors.Wrap(toAPIError(err), "error creating batch")
+	}
+
+	span.SetAttributes(attribute.String("ai.batch_id", batch.ID))
+
+	return toBatch(batch), nil
+}
+
+// Get the current state of a batch.
+func (b *Batcher) Get(ctx context.Context, id string) (Batch, error) {
+	batch, err := b.Client.Batches.Get(ctx, id)
+	if err != nil {
+		return Batch{}, errors.Wrap(toAPIError(err), "error getting batch")
+	}
+	return toBatch(batch), nil
+}
+
+// Cancel a batch. Cancellation is asynchronous, so the returned batch is usually in [BatchStatusCancelling].
+func (b *Batcher) Cancel(ctx context.Context, id string) (Batch, error) {
+	batch, err := b.Client.Batches.Cancel(ctx, id)
+	if err != nil {
+		return Batch{}, errors.Wrap(toAPIError(err), "error cancelling batch")
+	}
+	return toBatch(batch), nil
+}
+
+// Wait polls the batch until it's [Batch.Done] or the context is cancelled.
+func (b *Batcher) Wait(ctx context.Context, id string) (Batch, error) {
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		batch, err := b.Get(ctx, id)
+		if err != nil {
+			return Batch{}, err
+		}
+		if batch.Done() {
+			return batch, nil
+		}
+
+		b.log.Debug("Waiting for batch", "id", id, "status", batch.Status, "completed", batch.Completed, "total", batch.Total)
+
+		select {
+		case <-ctx.Done():
+			return batch, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ChatCompleteResults downloads the results of a finished chat completion batch, keyed by custom ID.
+// The responses are already complete, so iterating their parts doesn't make any requests.
+func (b *Batcher) ChatCompleteResults(ctx context.Context, batch Batch) (map[string]ChatCompleteBatchResult, error) {
+	results := map[string]ChatCompleteBatchResult{}
+	err := b.readResults(ctx, batch, func(customID string, body json.RawMessage, err error) error {
+		if err != nil {
+			results[customID] = ChatCompleteBatchResult{Err: err}
+			return nil
+		}
+
+		var completion openai.ChatCompletion
+		if err := json.Unmarshal(body, &completion); err != nil {
+			return errors.Wrap(err, "error decoding chat completion for %v", customID)
+		}
+		results[customID] = ChatCompleteBatchResult{Response: chatCompletionToResponse(completion)}
+		return nil
+	})
+	return results, err
+}
+
+// EmbedResults downloads the results of a finished embedding batch, keyed by custom ID.
+func (b *Batcher) EmbedResults(ctx context.Context, batch Batch) (map[string]EmbedBatchResult, error) {
+	results := map[string]EmbedBatchResult{}
+	err := b.readResults(ctx, batch, func(customID string, body json.RawMessage, err error) error {
+		if err != nil {
+			results[customID] = EmbedBatchResult{Err: err}
+			return nil
+		}
+
+		var res openai.CreateEmbeddingResponse
+		if err := json.Unmarshal(body, &res); err != nil {
+			return errors.Wrap(err, "error decoding embedding for %v", customID)
+		}
+		if len(res.Data) == 0 {
+			results[customID] = EmbedBatchResult{Err: errors.New("no embeddings returned")}
+			return nil
+		}
+		results[customID] = EmbedBatchResult{Response: gai.EmbedResponse[float64]{Embedding: res.Data[0].Embedding}}
+		return nil
+	})
+	return results, err
+}
+
+// readResults from both the output and error files of the batch, calling cb for each line.
+// Requests that failed have a nil body and a non-nil error.
+func (b *Batcher) readResults(ctx context.Context, batch Batch, cb func(customID string, body json.RawMessage, err error) error) error {
+	if !batch.Done() {
+		return errors.Newf("batch %v is not done, status is %v", batch.ID, batch.Status)
+	}
+
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+
+		if err := b.readResultFile(ctx, fileID, cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Batcher) readResultFile(ctx context.Context, fileID string, cb func(customID string, body json.RawMessage, err error) error) error {
+	res, err := b.Client.Files.Content(ctx, fileID)
+	if err != nil {
+		return errors.Wrap(toAPIError(err), "error downloading batch results")
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			b.log.Info("Error closing batch results body", "error", err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(res.Body)
+	// Lines can be large, for example with many embedding dimensions
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var line batchOutputLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return errors.Wrap(err, "error decoding batch result line")
+		}
+
+		switch {
+		case line.Error != nil:
+			err = cb(line.CustomID, nil, errors.Newf("batch request failed: %v: %v", line.Error.Code, line.Error.Message))
+		case line.Response == nil:
+			err = cb(line.CustomID, nil, errors.New("batch request has no response"))
+		case line.Response.StatusCode != 200:
+			err = cb(line.CustomID, nil, errors.Newf("batch request failed with status %v: %v", line.Response.StatusCode, string(line.Response.Body)))
+		default:
+			err = cb(line.CustomID, line.Response.Body, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "error reading batch results")
+	}
+	return nil
+}
+
+func toBatch(b *openai.Batch) Batch {
+	var errs []string
+	for _, e := range b.Errors.Data {
+		if e.Line > 0 {
+			errs = append(errs, fmt.Sprintf("line %v: %v", e.Line, e.Message))
+			continue
+		}
+		errs = append(errs, e.Message)
+	}
+
+	return Batch{
+		ID:           b.ID,
+		Status:       BatchStatus(b.Status),
+		Endpoint:     b.Endpoint,
+		Errors:       errs,
+		InputFileID:  b.InputFileID,
+		OutputFileID: b.OutputFileID,
+		ErrorFileID:  b.ErrorFileID,
+		Total:        int(b.RequestCounts.Total),
+		Completed:    int(b.RequestCounts.Completed),
+		Failed:       int(b.RequestCounts.Failed),
+	}
+}
+
+// chatCompletionToResponse converts a complete, non-streamed chat completion to a [gai.ChatCompleteResponse].
+func chatCompletionToResponse(completion openai.ChatCompletion) gai.ChatCompleteResponse {
+	meta := &gai.ChatCompleteResponseMetadata{
+		Usage: gai.ChatCompleteResponseUsage{
+			PromptTokens:     int(completion.Usage.PromptTokens),
+			CompletionTokens: int(completion.Usage.CompletionTokens),
+		},
+	}
+
+	if len(completion.Choices) > 0 && completion.Choices[0].FinishReason != "" {
+		meta.FinishReason = gai.Ptr(mapChatFinishReason(completion.Choices[0].FinishReason))
+	}
+
+	res := gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		if len(completion.Choices) == 0 {
+			return
+		}
+		message := completion.Choices[0].Message
+
+		if message.Refusal != "" {
+			meta.FinishReason = gai.Ptr(gai.ChatCompleteFinishReasonRefusal)
+			yield(gai.MessagePart{}, fmt.Errorf("refusal: %v", message.Refusal))
+			return
+		}
+
+		if message.Content != "" {
+			if !yield(gai.TextMessagePart(message.Content), nil) {
+				return
+			}
+		}
+
+		for _, toolCall := range message.ToolCalls {
+			if !yield(gai.ToolCallPart(toolCall.ID, toolCall.Function.Name, json.RawMessage(toolCall.Function.Arguments)), nil) {
+				return
+			}
+		}
+	})
+
+	res.Meta = meta
+
+	return res
+}
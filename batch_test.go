@@ -0,0 +1,177 @@
+package openai_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestChatCompleter_WriteBatchInput(t *testing.T) {
+	t.Run("writes one JSONL line per request with the chat completion params", func(t *testing.T) {
+		cc := newOfflineChatCompleter()
+
+		var buf bytes.Buffer
+		err := cc.WriteBatchInput(&buf, []openai.ChatCompleteBatchRequest{
+			{CustomID: "a", Request: gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Hi!")}}},
+			{CustomID: "b", Request: gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Bye!")}}},
+		})
+		is.NotError(t, err)
+
+		var lines []map[string]any
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var line map[string]any
+			is.NotError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+
+		is.Equal(t, 2, len(lines))
+		is.Equal(t, "a", lines[0]["custom_id"].(string))
+		is.Equal(t, "POST", lines[0]["method"].(string))
+		is.Equal(t, "/v1/chat/completions", lines[0]["url"].(string))
+
+		body := lines[0]["body"].(map[string]any)
+		is.Equal(t, string(openai.ChatCompleteModelGPT4oMini), body["model"].(string))
+		_, hasStreamOptions := body["stream_options"]
+		is.True(t, !hasStreamOptions, "should not have stream options")
+	})
+
+	t.Run("errors on duplicate custom IDs", func(t *testing.T) {
+		cc := newOfflineChatCompleter()
+
+		err := cc.WriteBatchInput(&bytes.Buffer{}, []openai.ChatCompleteBatchRequest{
+			{CustomID: "a", Request: gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Hi!")}}},
+			{CustomID: "a", Request: gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Bye!")}}},
+		})
+		is.True(t, err != nil, "should error")
+	})
+}
+
+func TestBatcher(t *testing.T) {
+	t.Run("can create and cancel a chat completion batch", func(t *testing.T) {
+		c := newClient(t)
+		b := c.NewBatcher(openai.NewBatcherOptions{})
+		cc := newChatCompleter(t)
+
+		batch, err := b.CreateChatCompleteBatch(t.Context(), cc, []openai.ChatCompleteBatchRequest{
+			{CustomID: "hi", Request: gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Hi!")}}},
+		})
+		is.NotError(t, err)
+		is.True(t, batch.ID != "", "should have an ID")
+		is.Equal(t, "/v1/chat/completions", batch.Endpoint)
+
+		batch, err = b.Cancel(t.Context(), batch.ID)
+		is.NotError(t, err)
+		is.True(t, batch.Status == openai.BatchStatusCancelling || batch.Status == openai.BatchStatusCancelled, "should be cancelling")
+	})
+
+	t.Run("can map chat completion results back by custom ID", func(t *testing.T) {
+		b := newBatcherWithFiles(t, map[string]string{
+			"file-out": `{"id":"r1","custom_id":"hi","response":{"status_code":200,"request_id":"req1","body":{"id":"c1","object":"chat.completion","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!","refusal":null},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}},"error":null}
+`,
+			"file-err": `{"id":"r2","custom_id":"bad","response":null,"error":{"code":"invalid_request","message":"nope"}}
+`,
+		})
+
+		results, err := b.ChatCompleteResults(t.Context(), openai.Batch{
+			ID:           "batch1",
+			Status:       openai.BatchStatusCompleted,
+			OutputFileID: "file-out",
+			ErrorFileID:  "file-err",
+		})
+		is.NotError(t, err)
+		is.Equal(t, 2, len(results))
+
+		hi := results["hi"]
+		is.NotError(t, hi.Err)
+		var output string
+		for part, err := range hi.Response.Parts() {
+			is.NotError(t, err)
+			output += part.Text()
+		}
+		is.Equal(t, "Hello!", output)
+		is.Equal(t, 9, hi.Response.Meta.Usage.PromptTokens)
+		is.Equal(t, gai.ChatCompleteFinishReasonStop, *hi.Response.Meta.FinishReason)
+
+		is.True(t, results["bad"].Err != nil, "should have an error")
+	})
+
+	t.Run("can map embedding results back by custom ID", func(t *testing.T) {
+		b := newBatcherWithFiles(t, map[string]string{
+			"file-out": `{"id":"r1","custom_id":"e1","response":{"status_code":200,"request_id":"req1","body":{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,0.2,0.3]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":3,"total_tokens":3}}},"error":null}
+`,
+		})
+
+		results, err := b.EmbedResults(t.Context(), openai.Batch{
+			ID:           "batch1",
+			Status:       openai.BatchStatusCompleted,
+			OutputFileID: "file-out",
+		})
+		is.NotError(t, err)
+		is.NotError(t, results["e1"].Err)
+		is.Equal(t, 3, len(results["e1"].Response.Embedding))
+	})
+
+	t.Run("returns API errors from uploading inputs and downloading results", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"Rate limit reached.","type":"requests","code":"rate_limit_exceeded"}}`))
+		}))
+		t.Cleanup(s.Close)
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", Retry: openai.RetryOptions{MaxRetries: -1}})
+		b := c.NewBatcher(openai.NewBatcherOptions{})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := b.CreateEmbedBatch(t.Context(), e, []openai.EmbedBatchRequest{{CustomID: "e1", Request: gai.EmbedRequest{Input: strings.NewReader("Hi!")}}})
+		var rateLimitErr *openai.RateLimitError
+		is.True(t, errors.As(err, &rateLimitErr), "should be a rate limit error")
+
+		_, err = b.EmbedResults(t.Context(), openai.Batch{ID: "batch1", Status: openai.BatchStatusCompleted, OutputFileID: "file-out"})
+		rateLimitErr = nil
+		is.True(t, errors.As(err, &rateLimitErr), "should be a rate limit error")
+	})
+
+	t.Run("errors when reading results of an unfinished batch", func(t *testing.T) {
+		b := newBatcherWithFiles(t, nil)
+
+		_, err := b.ChatCompleteResults(t.Context(), openai.Batch{ID: "batch1", Status: openai.BatchStatusInProgress})
+		is.True(t, err != nil, "should error")
+	})
+}
+
+// newBatcherWithFiles returns a [openai.Batcher] against a test server that serves the given file contents by file ID.
+func newBatcherWithFiles(t *testing.T, files map[string]string) *openai.Batcher {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/files/"), "/content")
+		content, ok := files[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+	return c.NewBatcher(openai.NewBatcherOptions{})
+}
+
+// newOfflineChatCompleter for tests that don't send requests.
+func newOfflineChatCompleter() *openai.ChatCompleter {
+	c := openai.NewClient(openai.NewClientOptions{Key: "test"})
+	return c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+}
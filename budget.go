@@ -0,0 +1,277 @@
+package openai
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+)
+
+// ErrBudgetExceeded is returned when a request would exceed the remaining daily budget of its tenant.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetStore stores the spend of tenants per day.
+// Days are UTC dates in the format 2006-01-02.
+// Implementations must be safe for concurrent use.
+type BudgetStore interface {
+	// Spent returns the total cost in US dollars spent by the tenant on the day.
+	Spent(ctx context.Context, tenant, day string) (float64, error)
+	// AddSpent adds the cost in US dollars to the spend of the tenant on the day.
+	AddSpent(ctx context.Context, tenant, day string, cost float64) error
+}
+
+// InMemoryBudgetStore is a [BudgetStore] that keeps spend in memory, so it's lost on restart
+// and not shared between processes.
+type InMemoryBudgetStore struct {
+	lock  sync.Mutex
+	spent map[string]map[string]float64
+}
+
+func NewInMemoryBudgetStore() *InMemoryBudgetStore {
+	return &InMemoryBudgetStore{spent: map[string]map[string]float64{}}
+}
+
+// Spent satisfies [BudgetStore].
+func (s *InMemoryBudgetStore) Spent(ctx context.Context, tenant, day string) (float64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.spent[day][tenant], nil
+}
+
+// AddSpent satisfies [BudgetStore]. Spend from days before the given day is forgotten.
+func (s *InMemoryBudgetStore) AddSpent(ctx context.Context, tenant, day string, cost float64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for d := range s.spent {
+		if d < day {
+			delete(s.spent, d)
+		}
+	}
+
+	if s.spent[day] == nil {
+		s.spent[day] = map[string]float64{}
+	}
+	s.spent[day][tenant] += cost
+	return nil
+}
+
+var _ BudgetStore = (*InMemoryBudgetStore)(nil)
+
+// BudgetGuard enforces daily spending limits per tenant, set with [WithTenant],
+// for the chat completers and embedders it wraps.
+// Costs are estimated from the registered [Price] of the model, so requests to models without a price are rejected.
+// Concurrent requests for the same tenant are checked against the same remaining budget,
+// so the limit can be exceeded by the cost of the requests in flight.
+type BudgetGuard struct {
+	dailyLimit        float64
+	log               *slog.Logger
+	store             BudgetStore
+	tenantDailyLimits map[string]float64
+}
+
+type NewBudgetGuardOptions struct {
+	// DailyLimit in US dollars for each tenant per UTC day.
+	// Zero, the default, rejects all requests from tenants that aren't in TenantDailyLimits.
+	DailyLimit float64
+	Log        *slog.Logger
+	// Store for spend. Defaults to a new [InMemoryBudgetStore].
+	Store BudgetStore
+	// TenantDailyLimits override DailyLimit for the given tenants. The map is copied.
+	TenantDailyLimits map[string]float64
+}
+
+func NewBudgetGuard(opts NewBudgetGuardOptions) *BudgetGuard {
+	if opts.DailyLimit < 0 {
+		panic("daily limit must not be negative")
+	}
+	if opts.Log == nil {
+		opts.Log = slog.New(slog.DiscardHandler)
+	}
+	if opts.Store == nil {
+		opts.Store = NewInMemoryBudgetStore()
+	}
+
+	return &BudgetGuard{
+		dailyLimit:        opts.DailyLimit,
+		log:               opts.Log,
+		store:             opts.Store,
+		tenantDailyLimits: maps.Clone(opts.TenantDailyLimits),
+	}
+}
+
+// withBudget returns a context with the remaining budget of the tenant in the context,
+// or [ErrBudgetExceeded] if there is none left.
+func (g *BudgetGuard) withBudget(ctx context.Context) (context.Context, error) {
+	tenant := tenantFromContext(ctx)
+	day := time.Now().UTC().Format(time.DateOnly)
+
+	limit, ok := g.tenantDailyLimits[tenant]
+	if !ok {
+		limit = g.dailyLimit
+	}
+
+	spent, err := g.store.Spent(ctx, tenant, day)
+	if err != nil {
+		return ctx, errors.Wrap(err, "error getting spent budget")
+	}
+
+	remaining := limit - spent
+	if remaining <= 0 {
+		return ctx, errors.Newf("%w: tenant %q has spent $%.4f of $%.4f today", ErrBudgetExceeded, tenant, spent, limit)
+	}
+
+	return context.WithValue(ctx, budgetContextKey{}, &budget{
+		remaining: remaining,
+		addSpent: func(cost float64) {
+			if err := g.store.AddSpent(context.WithoutCancel(ctx), tenant, day, cost); err != nil {
+				g.log.Info("Error adding spent budget", "error", err, "tenant", tenant, "cost", cost)
+			}
+		},
+	}), nil
+}
+
+type budgetContextKey struct{}
+
+// budget remaining for a single request, set by [BudgetGuard].
+type budget struct {
+	addSpent  func(cost float64)
+	remaining float64
+}
+
+func budgetFromContext(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetContextKey{}).(*budget)
+	return b
+}
+
+// check that the estimated prompt tokens for the model fit in the budget, and return the maximum number
+// of completion tokens the rest of the budget allows. It returns zero if there is no maximum, also if b is nil.
+func (b *budget) check(model string, promptTokens int) (int64, error) {
+	if b == nil {
+		return 0, nil
+	}
+
+	price, ok := GetPrice(model)
+	if !ok {
+		return 0, errors.Newf("no price registered for model %v, so the budget can't be enforced", model)
+	}
+
+	promptCost := price.Cost(Usage{PromptTokens: promptTokens})
+	if promptCost > b.remaining {
+		return 0, errors.Newf("%w: estimated prompt cost $%.4f is more than the remaining $%.4f", ErrBudgetExceeded,
+			promptCost, b.remaining)
+	}
+
+	outputPrice := max(price.Output, price.Reasoning)
+	if outputPrice == 0 {
+		return 0, nil
+	}
+
+	maxCompletionTokens := int64(math.Floor((b.remaining - promptCost) / outputPrice * 1_000_000))
+	if maxCompletionTokens < 1 {
+		return 0, errors.Newf("%w: no budget left for completion tokens", ErrBudgetExceeded)
+	}
+	return maxCompletionTokens, nil
+}
+
+// record the actual spend of the request. It does nothing if b is nil.
+func (b *budget) record(u Usage) {
+	if b == nil {
+		return
+	}
+	b.addSpent(u.Cost)
+}
+
+// estimateStreamedUsage of a chat completion stream without usage, so the budget is still charged.
+// That happens with servers that don't support stream options, and on errors mid-stream.
+// Prompt tokens are the estimate from before the request, and completion tokens are estimated from the streamed output.
+func estimateStreamedUsage(model string, promptTokens int, acc openai.ChatCompletionAccumulator) Usage {
+	var chars int
+	for _, choice := range acc.Choices {
+		chars += len(choice.Message.Content) + len(choice.Message.Refusal)
+		for _, toolCall := range choice.Message.ToolCalls {
+			chars += len(toolCall.Function.Name) + len(toolCall.Function.Arguments)
+		}
+	}
+
+	u := Usage{Requests: 1, PromptTokens: promptTokens}
+	if chars > 0 {
+		u.CompletionTokens = estimateTokensFromChars(chars)
+	}
+	u.Cost, _ = EstimateCost(model, u)
+	return u
+}
+
+// BudgetedChatCompleter wraps a [ChatCompleter] and enforces the budget of a [BudgetGuard].
+type BudgetedChatCompleter struct {
+	chatCompleter *ChatCompleter
+	guard         *BudgetGuard
+}
+
+type NewBudgetedChatCompleterOptions struct {
+	ChatCompleter *ChatCompleter
+}
+
+func (g *BudgetGuard) NewBudgetedChatCompleter(opts NewBudgetedChatCompleterOptions) *BudgetedChatCompleter {
+	if opts.ChatCompleter == nil {
+		panic("chat completer must not be nil")
+	}
+
+	return &BudgetedChatCompleter{
+		chatCompleter: opts.ChatCompleter,
+		guard:         g,
+	}
+}
+
+// ChatComplete satisfies [gai.ChatCompleter].
+// Requests whose estimated prompt cost exceeds the remaining budget are rejected with [ErrBudgetExceeded],
+// and max_completion_tokens is capped to what the rest of the budget allows.
+// The actual cost is recorded when the response has been read.
+func (b *BudgetedChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	ctx, err := b.guard.withBudget(ctx)
+	if err != nil {
+		return gai.ChatCompleteResponse{}, err
+	}
+	return b.chatCompleter.ChatComplete(ctx, req)
+}
+
+var _ gai.ChatCompleter = (*BudgetedChatCompleter)(nil)
+
+// BudgetedEmbedder wraps an [Embedder] and enforces the budget of a [BudgetGuard].
+type BudgetedEmbedder struct {
+	embedder *Embedder
+	guard    *BudgetGuard
+}
+
+type NewBudgetedEmbedderOptions struct {
+	Embedder *Embedder
+}
+
+func (g *BudgetGuard) NewBudgetedEmbedder(opts NewBudgetedEmbedderOptions) *BudgetedEmbedder {
+	if opts.Embedder == nil {
+		panic("embedder must not be nil")
+	}
+
+	return &BudgetedEmbedder{
+		embedder: opts.Embedder,
+		guard:    g,
+	}
+}
+
+// Embed satisfies [gai.Embedder].
+// Requests whose estimated cost exceeds the remaining budget are rejected with [ErrBudgetExceeded].
+func (b *BudgetedEmbedder) Embed(ctx context.Context, req gai.EmbedRequest) (gai.EmbedResponse[float64], error) {
+	ctx, err := b.guard.withBudget(ctx)
+	if err != nil {
+		return gai.EmbedResponse[float64]{}, err
+	}
+	return b.embedder.Embed(ctx, req)
+}
+
+var _ gai.Embedder[float64] = (*BudgetedEmbedder)(nil)
@@ -0,0 +1,218 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestBudgetGuard(t *testing.T) {
+	t.Run("caps max completion tokens to the remaining budget and records the actual spend", func(t *testing.T) {
+		var body map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":1000,"completion_tokens":1000,"total_tokens":2000}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		store := openai.NewInMemoryBudgetStore()
+		g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{
+			DailyLimit:        1,
+			Store:             store,
+			TenantDailyLimits: map[string]float64{"a": 0.0006},
+		})
+		cc := g.NewBudgetedChatCompleter(openai.NewBudgetedChatCompleterOptions{ChatCompleter: newBudgetChatCompleter(s.URL)})
+
+		ctx := openai.WithTenant(t.Context(), "a")
+		res, err := cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		maxCompletionTokens, ok := body["max_completion_tokens"].(float64)
+		is.True(t, ok, "should have max_completion_tokens")
+		is.True(t, maxCompletionTokens > 900 && maxCompletionTokens < 1000, "should be capped to the budget")
+
+		spent, err := store.Spent(t.Context(), "a", time.Now().UTC().Format(time.DateOnly))
+		is.NotError(t, err)
+		is.Equal(t, (1000*0.15+1000*0.60)/1_000_000, spent)
+
+		_, err = cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.True(t, errors.Is(err, openai.ErrBudgetExceeded), "should be over budget")
+	})
+
+	t.Run("records an estimated spend if the stream has no usage", func(t *testing.T) {
+		// About 7000 characters of output is estimated at about 1750 completion tokens
+		const minOutputCost = 1700 * 0.60 / 1_000_000
+
+		for _, test := range []struct {
+			name string
+			fail bool
+		}{
+			{"without stream options", false},
+			{"with an error mid-stream", true},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "text/event-stream")
+					_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"` + strings.Repeat("Hello! ", 1000) + `"}}]}` + "\n\n"))
+					if test.fail {
+						_, _ = w.Write([]byte(`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error"}}` + "\n\n"))
+						return
+					}
+					_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+					_, _ = w.Write([]byte("data: [DONE]\n\n"))
+				}))
+				defer s.Close()
+
+				store := openai.NewInMemoryBudgetStore()
+				g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{DailyLimit: 1, Store: store})
+
+				c := openai.NewClient(openai.NewClientOptions{
+					BaseURL:       s.URL,
+					Key:           "test",
+					Compatibility: &openai.Compatibility{NoStreamOptions: true},
+				})
+				cc := g.NewBudgetedChatCompleter(openai.NewBudgetedChatCompleterOptions{
+					ChatCompleter: c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini}),
+				})
+
+				res, err := cc.ChatComplete(openai.WithTenant(t.Context(), "a"), gai.ChatCompleteRequest{
+					Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+				})
+				is.NotError(t, err)
+				for range res.Parts() {
+				}
+
+				spent, err := store.Spent(t.Context(), "a", time.Now().UTC().Format(time.DateOnly))
+				is.NotError(t, err)
+				is.True(t, spent > minOutputCost, "should have recorded an estimated spend")
+			})
+		}
+	})
+
+	t.Run("rejects requests whose estimated prompt cost exceeds the remaining budget", func(t *testing.T) {
+		var called bool
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer s.Close()
+
+		g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{DailyLimit: 0.000001})
+		cc := g.NewBudgetedChatCompleter(openai.NewBudgetedChatCompleterOptions{ChatCompleter: newBudgetChatCompleter(s.URL)})
+
+		_, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage(strings.Repeat("Hi! ", 1000))},
+		})
+		is.True(t, errors.Is(err, openai.ErrBudgetExceeded), "should be over budget")
+		is.True(t, !called, "should not call the API")
+	})
+
+	t.Run("rejects tenants without a daily limit override if the daily limit is zero", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		limits := map[string]float64{"a": 1}
+		g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{TenantDailyLimits: limits})
+		cc := g.NewBudgetedChatCompleter(openai.NewBudgetedChatCompleterOptions{ChatCompleter: newBudgetChatCompleter(s.URL)})
+
+		// Changing the map after creating the guard has no effect
+		limits["b"] = 1
+
+		_, err := cc.ChatComplete(openai.WithTenant(t.Context(), "b"), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.True(t, errors.Is(err, openai.ErrBudgetExceeded), "should be over budget")
+
+		res, err := cc.ChatComplete(openai.WithTenant(t.Context(), "a"), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+	})
+
+	t.Run("rejects requests to models without a price", func(t *testing.T) {
+		g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{DailyLimit: 1})
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: "http://localhost:0", Key: "test"})
+		cc := g.NewBudgetedChatCompleter(openai.NewBudgetedChatCompleterOptions{
+			ChatCompleter: c.NewChatCompleter(openai.NewChatCompleterOptions{Model: "unknown"}),
+		})
+
+		_, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.True(t, err != nil, "should error")
+		is.True(t, strings.Contains(err.Error(), "no price registered"), err.Error())
+	})
+
+	t.Run("records the spend of embeddings", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		store := openai.NewInMemoryBudgetStore()
+		g := openai.NewBudgetGuard(openai.NewBudgetGuardOptions{DailyLimit: 1, Store: store})
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+		e := g.NewBudgetedEmbedder(openai.NewBudgetedEmbedderOptions{
+			Embedder: c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3}),
+		})
+
+		_, err := e.Embed(openai.WithTenant(t.Context(), "b"), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		spent, err := store.Spent(t.Context(), "b", time.Now().UTC().Format(time.DateOnly))
+		is.NotError(t, err)
+		is.Equal(t, 0.02/1_000_000, spent)
+	})
+}
+
+func TestInMemoryBudgetStore(t *testing.T) {
+	t.Run("adds spend per tenant and day, and forgets earlier days", func(t *testing.T) {
+		s := openai.NewInMemoryBudgetStore()
+
+		is.NotError(t, s.AddSpent(t.Context(), "a", "2025-01-01", 1))
+		is.NotError(t, s.AddSpent(t.Context(), "a", "2025-01-02", 2))
+		is.NotError(t, s.AddSpent(t.Context(), "a", "2025-01-02", 3))
+		is.NotError(t, s.AddSpent(t.Context(), "b", "2025-01-02", 4))
+
+		spent, err := s.Spent(t.Context(), "a", "2025-01-02")
+		is.NotError(t, err)
+		is.Equal(t, 5.0, spent)
+
+		spent, err = s.Spent(t.Context(), "a", "2025-01-01")
+		is.NotError(t, err)
+		is.Equal(t, 0.0, spent)
+	})
+}
+
+func newBudgetChatCompleter(baseURL string) *openai.ChatCompleter {
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: baseURL, Key: "test"})
+	return c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+}
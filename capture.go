@@ -0,0 +1,212 @@
+package openai
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/openai/openai-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/gai"
+)
+
+// ContentCaptureOptions for recording prompt and response content as span events and in debug logs.
+// Nothing is captured by default, because content can contain personal or confidential data.
+type ContentCaptureOptions struct {
+	// SystemPrompt captures system prompts as gen_ai.system.message events.
+	SystemPrompt bool
+	// Messages captures user messages, model messages, and tool results as gen_ai.user.message,
+	// gen_ai.assistant.message, and gen_ai.tool.message events.
+	Messages bool
+	// ToolArguments captures the arguments of tool calls, both in model messages and in the output.
+	ToolArguments bool
+	// Output captures the model output as a gen_ai.choice event.
+	Output bool
+	// Redactor is applied to all captured content before truncation.
+	Redactor Redactor
+	// MaxLength of each captured content in bytes. Longer content is truncated. Defaults to 8192.
+	// Set to a negative number to disable truncation.
+	MaxLength int
+}
+
+// Redactor returns the given content with sensitive data removed.
+type Redactor func(content string) string
+
+// Patterns for common personal data, for use with [NewRegexpRedactor].
+var (
+	CreditCardPattern  = regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`)
+	EmailPattern       = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	PhoneNumberPattern = regexp.MustCompile(`\+?\d[\d -]{7,}\d`)
+)
+
+// NewRegexpRedactor returns a [Redactor] that replaces all matches of the given patterns with "[REDACTED]".
+func NewRegexpRedactor(patterns ...*regexp.Regexp) Redactor {
+	return func(content string) string {
+		for _, p := range patterns {
+			content = p.ReplaceAllString(content, "[REDACTED]")
+		}
+		return content
+	}
+}
+
+const truncatedSuffix = "…[truncated]"
+
+// contentCapture records content on spans according to [ContentCaptureOptions].
+type contentCapture struct {
+	opts ContentCaptureOptions
+}
+
+func newContentCapture(opts ContentCaptureOptions) contentCapture {
+	if opts.MaxLength == 0 {
+		opts.MaxLength = 8192
+	}
+	return contentCapture{opts: opts}
+}
+
+// clean content by redacting and truncating it.
+func (c contentCapture) clean(content string) string {
+	if c.opts.Redactor != nil {
+		content = c.opts.Redactor(content)
+	}
+	if c.opts.MaxLength < 0 || len(content) <= c.opts.MaxLength {
+		return content
+	}
+
+	// Don't cut a multi-byte character in half
+	end := c.opts.MaxLength
+	for end > 0 && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	return content[:end] + truncatedSuffix
+}
+
+// capturedMessage is a request message or model output, with the content allowed by [ContentCaptureOptions].
+// It's used for both span events and logs.
+type capturedMessage struct {
+	Role         string             `json:"role"`
+	Content      *string            `json:"content,omitempty"`
+	ToolCallID   string             `json:"tool_call_id,omitempty"`
+	ToolCalls    []capturedToolCall `json:"tool_calls,omitempty"`
+	FinishReason string             `json:"finish_reason,omitempty"`
+}
+
+type capturedToolCall struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Arguments *string `json:"arguments,omitempty"`
+}
+
+// messages captured from the request parameters, which are used instead of the gai request,
+// because message part content can only be read once.
+func (c contentCapture) messages(params openai.ChatCompletionNewParams) []capturedMessage {
+	if !c.opts.SystemPrompt && !c.opts.Messages && !c.opts.ToolArguments {
+		return nil
+	}
+
+	var messages []capturedMessage
+	for _, m := range params.Messages {
+		switch {
+		case m.OfSystem != nil && c.opts.SystemPrompt:
+			content := m.OfSystem.Content.OfString.Value
+			for _, part := range m.OfSystem.Content.OfArrayOfContentParts {
+				content += part.Text
+			}
+			messages = append(messages, capturedMessage{Role: "system", Content: gai.Ptr(c.clean(content))})
+
+		case m.OfUser != nil && c.opts.Messages:
+			content := m.OfUser.Content.OfString.Value
+			for _, part := range m.OfUser.Content.OfArrayOfContentParts {
+				if part.OfText != nil {
+					content += part.OfText.Text
+				}
+			}
+			messages = append(messages, capturedMessage{Role: "user", Content: gai.Ptr(c.clean(content))})
+
+		case m.OfAssistant != nil && (c.opts.Messages || c.opts.ToolArguments):
+			message := capturedMessage{Role: "assistant"}
+			if c.opts.Messages {
+				content := m.OfAssistant.Content.OfString.Value
+				for _, part := range m.OfAssistant.Content.OfArrayOfContentParts {
+					if part.OfText != nil {
+						content += part.OfText.Text
+					}
+				}
+				message.Content = gai.Ptr(c.clean(content))
+			}
+			for _, toolCall := range m.OfAssistant.ToolCalls {
+				message.ToolCalls = append(message.ToolCalls, c.toolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+			}
+			messages = append(messages, message)
+
+		case m.OfTool != nil && c.opts.Messages:
+			content := m.OfTool.Content.OfString.Value
+			for _, part := range m.OfTool.Content.OfArrayOfContentParts {
+				content += part.Text
+			}
+			messages = append(messages, capturedMessage{Role: "tool", Content: gai.Ptr(c.clean(content)), ToolCallID: m.OfTool.ToolCallID})
+		}
+	}
+	return messages
+}
+
+// choice captured from the accumulated model output. It returns false if output isn't captured.
+func (c contentCapture) choice(acc openai.ChatCompletionAccumulator) (capturedMessage, bool) {
+	if (!c.opts.Output && !c.opts.ToolArguments) || len(acc.Choices) == 0 {
+		return capturedMessage{}, false
+	}
+
+	message := capturedMessage{Role: "assistant", FinishReason: acc.Choices[0].FinishReason}
+	if c.opts.Output {
+		message.Content = gai.Ptr(c.clean(acc.Choices[0].Message.Content))
+	}
+	for _, toolCall := range acc.Choices[0].Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, c.toolCall(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+	}
+	return message, true
+}
+
+// toolCall always includes the tool call name and ID, and the arguments only if captured.
+func (c contentCapture) toolCall(id, name, arguments string) capturedToolCall {
+	toolCall := capturedToolCall{ID: id, Name: name}
+	if c.opts.ToolArguments {
+		toolCall.Arguments = gai.Ptr(c.clean(arguments))
+	}
+	return toolCall
+}
+
+// input adds the captured request messages to the span as events.
+func (c contentCapture) input(span trace.Span, messages []capturedMessage) {
+	for _, m := range messages {
+		attrs := m.attributes()
+		if len(attrs) == 0 {
+			continue
+		}
+		span.AddEvent("gen_ai."+m.Role+".message", trace.WithAttributes(attrs...))
+	}
+}
+
+// output adds the captured model output to the span as an event.
+func (c contentCapture) output(span trace.Span, choice capturedMessage) {
+	attrs := append([]attribute.KeyValue{attribute.String("finish_reason", choice.FinishReason)}, choice.attributes()...)
+	span.AddEvent("gen_ai.choice", trace.WithAttributes(attrs...))
+}
+
+func (m capturedMessage) attributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if m.ToolCallID != "" {
+		attrs = append(attrs, attribute.String("id", m.ToolCallID))
+	}
+	if m.Content != nil {
+		attrs = append(attrs, attribute.String("content", *m.Content))
+	}
+	for _, toolCall := range m.ToolCalls {
+		attrs = append(attrs,
+			attribute.String("tool_call.id", toolCall.ID),
+			attribute.String("tool_call.name", toolCall.Name),
+		)
+		if toolCall.Arguments != nil {
+			attrs = append(attrs, attribute.String("tool_call.arguments", *toolCall.Arguments))
+		}
+	}
+	return attrs
+}
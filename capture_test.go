@@ -0,0 +1,125 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_ContentCapture(t *testing.T) {
+	t.Run("captures no content by default", func(t *testing.T) {
+		span := chatCompleteWithCapture(t, openai.ContentCaptureOptions{})
+
+		attrs := attributesOf(span)
+		is.True(t, attrs["ai.has_system_prompt"].AsBool())
+		_, ok := attrs["ai.system_prompt"]
+		is.True(t, !ok, "should not have system prompt attribute")
+		is.Equal(t, 0, len(span.Events()))
+	})
+
+	t.Run("captures redacted and truncated content as span events if enabled", func(t *testing.T) {
+		span := chatCompleteWithCapture(t, openai.ContentCaptureOptions{
+			SystemPrompt:  true,
+			Messages:      true,
+			ToolArguments: true,
+			Output:        true,
+			Redactor:      openai.NewRegexpRedactor(openai.EmailPattern),
+			MaxLength:     32,
+		})
+
+		events := eventsOf(span)
+		is.Equal(t, "You are a helpful assistant.", events["gen_ai.system.message"]["content"].AsString())
+		is.Equal(t, "My email is [REDACTED].", events["gen_ai.user.message"]["content"].AsString())
+		is.Equal(t, "get_weather", events["gen_ai.assistant.message"]["tool_call.name"].AsString())
+		is.Equal(t, `{"email":"[REDACTED]"}`, events["gen_ai.assistant.message"]["tool_call.arguments"].AsString())
+		is.Equal(t, "Sunny", events["gen_ai.tool.message"]["content"].AsString())
+		is.Equal(t, "Hello! This is a long response t…[truncated]", events["gen_ai.choice"]["content"].AsString())
+		is.Equal(t, "stop", events["gen_ai.choice"]["finish_reason"].AsString())
+	})
+
+	t.Run("does not capture tool call arguments unless enabled", func(t *testing.T) {
+		span := chatCompleteWithCapture(t, openai.ContentCaptureOptions{Messages: true})
+
+		events := eventsOf(span)
+		is.Equal(t, "call_1", events["gen_ai.assistant.message"]["tool_call.id"].AsString())
+		_, ok := events["gen_ai.assistant.message"]["tool_call.arguments"]
+		is.True(t, !ok, "should not have tool call arguments")
+		_, ok = events["gen_ai.system.message"]
+		is.True(t, !ok, "should not have system message")
+		_, ok = events["gen_ai.choice"]
+		is.True(t, !ok, "should not have output")
+	})
+}
+
+func TestNewRegexpRedactor(t *testing.T) {
+	t.Run("redacts emails, phone numbers, and credit card numbers", func(t *testing.T) {
+		r := openai.NewRegexpRedactor(openai.EmailPattern, openai.CreditCardPattern, openai.PhoneNumberPattern)
+		is.Equal(t, "Mail [REDACTED], call [REDACTED], pay with [REDACTED].",
+			r("Mail me@example.com, call +45 12 34 56 78, pay with 4111 1111 1111 1111."))
+	})
+}
+
+func chatCompleteWithCapture(t *testing.T, opts openai.ContentCaptureOptions) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello! This is a long response that will be truncated."}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(s.Close)
+
+	recorder := tracetest.NewSpanRecorder()
+	c := openai.NewClient(openai.NewClientOptions{
+		BaseURL:        s.URL,
+		ContentCapture: opts,
+		Key:            "test",
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+	})
+	cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+	res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+		System: gai.Ptr("You are a helpful assistant."),
+		Messages: []gai.Message{
+			gai.NewUserTextMessage("My email is me@example.com."),
+			{Role: gai.MessageRoleModel, Parts: []gai.MessagePart{
+				gai.ToolCallPart("call_1", "get_weather", json.RawMessage(`{"email":"me@example.com"}`)),
+			}},
+			gai.NewUserToolResultMessage(gai.ToolResult{ID: "call_1", Name: "get_weather", Content: "Sunny"}),
+		},
+	})
+	is.NotError(t, err)
+	var output strings.Builder
+	for part, err := range res.Parts() {
+		is.NotError(t, err)
+		output.WriteString(part.Text())
+	}
+	is.Equal(t, "Hello! This is a long response that will be truncated.", output.String())
+
+	spans := recorder.Ended()
+	is.Equal(t, 1, len(spans))
+	return spans[0]
+}
+
+func eventsOf(span sdktrace.ReadOnlySpan) map[string]map[attribute.Key]attribute.Value {
+	events := map[string]map[attribute.Key]attribute.Value{}
+	for _, e := range span.Events() {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range e.Attributes {
+			attrs[kv.Key] = kv.Value
+		}
+		events[e.Name] = attrs
+	}
+	return events
+}
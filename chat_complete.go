@@ -5,15 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
 	"github.com/openai/openai-go/shared"
-	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
 	"maragu.dev/gai"
 )
 
@@ -25,43 +28,401 @@ const (
 )
 
 type ChatCompleter struct {
-	Client openai.Client
-	log    *slog.Logger
-	model  ChatCompleteModel
-	tracer trace.Tracer
+	Client               openai.Client
+	compatibility        Compatibility
+	contentCapture       contentCapture
+	legacySpanAttributes bool
+	log                  *slog.Logger
+	metrics              *metrics
+	model                ChatCompleteModel
+	promptCache          PromptCacheOptions
+	rateLimiter          *rateLimiter
+	retrier              *retrier
+	system               string
+	tracer               trace.Tracer
+	usageAccumulator     *UsageAccumulator
 }
 
 type NewChatCompleterOptions struct {
 	Model ChatCompleteModel
+	// PromptCache options for all requests. They can be overridden per request with [WithPromptCache].
+	PromptCache PromptCacheOptions
 }
 
 func (c *Client) NewChatCompleter(opts NewChatCompleterOptions) *ChatCompleter {
+	if opts.Model == "" {
+		opts.Model = c.defaultChatCompleteModel
+	}
+
 	return &ChatCompleter{
-		Client: c.Client,
-		log:    c.log,
-		model:  opts.Model,
-		tracer: otel.Tracer("maragu.dev/gai-openai"),
+		Client:               c.Client,
+		compatibility:        c.compatibility,
+		contentCapture:       c.contentCapture,
+		legacySpanAttributes: c.legacySpanAttributes,
+		log:                  c.log,
+		metrics:              c.metrics,
+		model:                opts.Model,
+		promptCache:          opts.PromptCache,
+		rateLimiter:          c.rateLimiter,
+		retrier:              c.retrier,
+		system:               c.system,
+		tracer:               c.tracer,
+		usageAccumulator:     c.usageAccumulator,
 	}
 }
 
 // ChatComplete satisfies [gai.ChatCompleter].
 func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
-	ctx, span := c.tracer.Start(ctx, "openai.chat_complete",
+	attrs := append(genAIAttributes("chat", c.system, string(c.model)),
+		attribute.Int("ai.message_count", len(req.Messages)),
+	)
+	if c.legacySpanAttributes {
+		attrs = append(attrs, attribute.String("ai.model", string(c.model)))
+	}
+
+	ctx, span := c.tracer.Start(ctx, "chat "+string(c.model),
 		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
-			attribute.String("ai.model", string(c.model)),
-			attribute.Int("ai.message_count", len(req.Messages)),
-		),
+		trace.WithAttributes(attrs...),
 	)
 
+	requestMetrics := c.metrics.start("chat", string(c.model))
+
+	var params openai.ChatCompletionNewParams
+	err := checkChatCompleteRequest(c.model, req)
+	if err == nil {
+		params, err = c.NewParams(req)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid request")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		span.End()
+		requestMetrics.end(ctx, 0, 0, err)
+		return gai.ChatCompleteResponse{}, err
+	}
+
+	if req.System != nil {
+		span.SetAttributes(attribute.Bool("ai.has_system_prompt", true))
+	}
+
+	var toolNames []string
+	for _, tool := range req.Tools {
+		toolNames = append(toolNames, tool.Name)
+	}
+	sort.Strings(toolNames)
+	span.SetAttributes(
+		attribute.Int("ai.tool_count", len(req.Tools)),
+		attribute.StringSlice("ai.tools", toolNames),
+	)
+
+	if req.Temperature != nil {
+		span.SetAttributes(genAIRequestTemperature.Float64(req.Temperature.Float64()))
+		if c.legacySpanAttributes {
+			span.SetAttributes(attribute.Float64("ai.temperature", req.Temperature.Float64()))
+		}
+	}
+
+	if req.ResponseSchema != nil {
+		span.SetAttributes(attribute.Bool("ai.has_response_schema", true))
+	}
+
+	promptCacheFromContext(ctx).apply(&params)
+	if params.PromptCacheKey.Valid() {
+		span.SetAttributes(genAIOpenAIRequestPromptCacheKey.String(params.PromptCacheKey.Value))
+	}
+	if !c.compatibility.NoStreamOptions {
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+			IncludeUsage: openai.Bool(true),
+		}
+	}
+
+	messages := c.contentCapture.messages(params)
+	c.contentCapture.input(span, messages)
+
+	if c.log.Enabled(ctx, slog.LevelDebug) {
+		args := []any{"model", c.model, "messageCount", len(req.Messages), "tools", toolNames,
+			"hasSystemPrompt", req.System != nil, "hasResponseSchema", req.ResponseSchema != nil}
+		if req.Temperature != nil {
+			args = append(args, "temperature", req.Temperature.Float64())
+		}
+		if len(messages) > 0 {
+			args = append(args, "messages", messages)
+		}
+		c.log.DebugContext(ctx, "Chat completion request", args...)
+	}
+
+	estimatedTokens := estimateTokens(params)
+
+	requestBudget := budgetFromContext(ctx)
+	maxCompletionTokens, err := requestBudget.check(string(c.model), estimatedTokens)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "budget exceeded")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		span.End()
+		requestMetrics.end(ctx, 0, 0, err)
+		return gai.ChatCompleteResponse{}, err
+	}
+	if maxCompletionTokens > 0 && (!params.MaxCompletionTokens.Valid() || params.MaxCompletionTokens.Value > maxCompletionTokens) {
+		params.MaxCompletionTokens = openai.Int(maxCompletionTokens)
+	}
+
+	if err := c.rateLimiter.wait(ctx, estimatedTokens); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "rate limited")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		span.End()
+		requestMetrics.end(ctx, 0, 0, err)
+		return gai.ChatCompleteResponse{}, err
+	}
+
+	// Retries of the request are charged to the rate limiter too
+	requestCtx := withRateLimit(ctx, c.rateLimiter, estimatedTokens)
+
+	start := time.Now()
+	var httpRes *http.Response
+	stream := c.Client.Chat.Completions.NewStreaming(requestCtx, params, option.WithResponseInto(&httpRes))
+
+	meta := &gai.ChatCompleteResponseMetadata{}
+
+	res := gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		defer span.End()
+
+		var acc openai.ChatCompletionAccumulator
+		var streamErr error
+		usage := Usage{Requests: 1}
+		defer func() {
+			choice, captured := c.contentCapture.choice(acc)
+			if captured {
+				c.contentCapture.output(span, choice)
+			}
+
+			if !c.log.Enabled(ctx, slog.LevelDebug) {
+				return
+			}
+			args := []any{"model", c.model, "requestID", requestIDOf(httpRes), "responseID", acc.ID,
+				"promptTokens", usage.PromptTokens, "cachedPromptTokens", usage.CachedPromptTokens,
+				"completionTokens", usage.CompletionTokens, "reasoningTokens", usage.ReasoningTokens,
+				"cost", usage.Cost, "duration", time.Since(start)}
+			if meta.FinishReason != nil {
+				args = append(args, "finishReason", *meta.FinishReason)
+			}
+			if streamErr != nil {
+				args = append(args, "error", streamErr)
+			}
+			if captured {
+				args = append(args, "output", choice)
+			}
+			c.log.DebugContext(ctx, "Chat completion response", args...)
+		}()
+
+		defer func() {
+			requestMetrics.end(ctx, meta.Usage.PromptTokens, meta.Usage.CompletionTokens, streamErr)
+			if streamErr != nil {
+				span.SetAttributes(errorTypeKey.String(errorType(streamErr)))
+			}
+		}()
+
+		defer func() {
+			c.rateLimiter.reconcile(estimatedTokens, meta.Usage.PromptTokens+meta.Usage.CompletionTokens)
+			c.usageAccumulator.record(ctx, usage)
+			// Without usage in the stream, charge the budget an estimate if the server accepted the request
+			if usage.PromptTokens == 0 && httpRes != nil && httpRes.StatusCode < 300 {
+				requestBudget.record(estimateStreamedUsage(string(c.model), estimatedTokens, acc))
+			} else {
+				requestBudget.record(usage)
+			}
+			storeUsageInto(ctx, usage)
+		}()
+
+		defer func() {
+			if err := stream.Close(); err != nil {
+				c.log.Info("Error closing stream", "error", err)
+			}
+		}()
+
+		// Errors in the stream are retried, but only if nothing has been yielded yet,
+		// because the caller can't take back parts it has already received.
+		var yielded, responseAttributesSet bool
+		for attempt := 0; ; attempt++ {
+			acc = openai.ChatCompletionAccumulator{}
+			for stream.Next() {
+				chunk := stream.Current()
+				acc.AddChunk(chunk)
+
+				if !responseAttributesSet && chunk.ID != "" {
+					span.SetAttributes(genAIResponseID.String(chunk.ID), genAIResponseModel.String(chunk.Model))
+					responseAttributesSet = true
+				}
+
+				if len(chunk.Choices) > 0 {
+					if reason := chunk.Choices[0].FinishReason; reason != "" {
+						mapped := c.compatibility.mapFinishReason(reason)
+						if meta.FinishReason == nil || *meta.FinishReason != mapped {
+							meta.FinishReason = gai.Ptr(mapped)
+						}
+						c.setFinishReasonAttributes(span, reason, mapped)
+					}
+				}
+
+				if _, ok := acc.JustFinishedContent(); !ok {
+					if toolCall, ok := acc.JustFinishedToolCall(); ok {
+						yielded = true
+						requestMetrics.token(ctx)
+						if !yield(gai.ToolCallPart(toolCall.ID, toolCall.Name, json.RawMessage(toolCall.Arguments)), nil) {
+							return
+						}
+						continue
+					}
+
+					if refusal, ok := acc.JustFinishedRefusal(); ok {
+						err := fmt.Errorf("refusal: %v", refusal)
+						meta.FinishReason = gai.Ptr(gai.ChatCompleteFinishReasonRefusal)
+						c.setFinishReasonAttributes(span, string(gai.ChatCompleteFinishReasonRefusal), gai.ChatCompleteFinishReasonRefusal)
+						span.RecordError(err)
+						span.SetStatus(codes.Error, "model refused request")
+						streamErr = err
+						yield(gai.MessagePart{}, err)
+						return
+					}
+
+					if len(chunk.Choices) > 0 {
+						if chunk.Choices[0].Delta.Content != "" {
+							yielded = true
+							requestMetrics.token(ctx)
+						}
+						if !yield(gai.TextMessagePart(chunk.Choices[0].Delta.Content), nil) {
+							return
+						}
+					}
+				}
+
+				if chunk.Usage.PromptTokens == 0 {
+					continue
+				}
+
+				meta.Usage = gai.ChatCompleteResponseUsage{
+					PromptTokens:     int(chunk.Usage.PromptTokens),
+					CompletionTokens: int(chunk.Usage.CompletionTokens),
+				}
+				usage = Usage{
+					Requests:                 1,
+					PromptTokens:             int(chunk.Usage.PromptTokens),
+					CachedPromptTokens:       int(chunk.Usage.PromptTokensDetails.CachedTokens),
+					CompletionTokens:         int(chunk.Usage.CompletionTokens),
+					ReasoningTokens:          int(chunk.Usage.CompletionTokensDetails.ReasoningTokens),
+					AudioPromptTokens:        int(chunk.Usage.PromptTokensDetails.AudioTokens),
+					AudioCompletionTokens:    int(chunk.Usage.CompletionTokensDetails.AudioTokens),
+					AcceptedPredictionTokens: int(chunk.Usage.CompletionTokensDetails.AcceptedPredictionTokens),
+					RejectedPredictionTokens: int(chunk.Usage.CompletionTokensDetails.RejectedPredictionTokens),
+				}
+				if cost, ok := EstimateCost(string(c.model), usage); ok {
+					usage.Cost = cost
+					span.SetAttributes(genAIUsageEstimatedCostUSD.Float64(cost))
+				}
+				span.SetAttributes(
+					genAIUsageInputTokens.Int(int(chunk.Usage.PromptTokens)),
+					genAIUsageOutputTokens.Int(int(chunk.Usage.CompletionTokens)),
+					genAIUsageCacheReadInputTokens.Int(usage.CachedPromptTokens),
+					genAIUsageReasoningOutputTokens.Int(usage.ReasoningTokens),
+					genAIUsageAudioInputTokens.Int(usage.AudioPromptTokens),
+					genAIUsageAudioOutputTokens.Int(usage.AudioCompletionTokens),
+					genAIUsageAcceptedPredictionOutputTokens.Int(usage.AcceptedPredictionTokens),
+					genAIUsageRejectedPredictionOutputTokens.Int(usage.RejectedPredictionTokens),
+				)
+				if c.legacySpanAttributes {
+					span.SetAttributes(
+						attribute.Int("ai.prompt_tokens", int(chunk.Usage.PromptTokens)),
+						attribute.Int("ai.completion_tokens", int(chunk.Usage.CompletionTokens)),
+						attribute.Int("ai.total_tokens", int(chunk.Usage.TotalTokens)),
+					)
+				}
+			}
+
+			if meta.FinishReason == nil && len(acc.Choices) > 0 {
+				if reason := acc.Choices[0].FinishReason; reason != "" {
+					mapped := c.compatibility.mapFinishReason(reason)
+					meta.FinishReason = gai.Ptr(mapped)
+					c.setFinishReasonAttributes(span, reason, mapped)
+				}
+			}
+
+			err := stream.Err()
+			if err != nil && !yielded && attempt < c.retrier.maxRetries && isRetryableStreamError(ctx, err) {
+				delay := c.retrier.backoff(attempt)
+				c.log.Info("Retrying chat completion stream", "error", err, "requestID", requestIDOf(httpRes),
+					"attempt", attempt+1, "delay", delay)
+				span.AddEvent("retry", trace.WithAttributes(attribute.String("error", err.Error())))
+
+				if err := sleep(ctx, delay); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "stream error")
+					streamErr = err
+					yield(gai.MessagePart{}, err)
+					return
+				}
+
+				if err := c.rateLimiter.wait(ctx, estimatedTokens); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "rate limited")
+					span.SetAttributes(errorTypeKey.String(errorType(err)))
+					streamErr = err
+					yield(gai.MessagePart{}, err)
+					return
+				}
+
+				if err := stream.Close(); err != nil {
+					c.log.Info("Error closing stream", "error", err)
+				}
+				stream = c.Client.Chat.Completions.NewStreaming(requestCtx, params, option.WithResponseInto(&httpRes))
+				*meta = gai.ChatCompleteResponseMetadata{}
+				usage = Usage{Requests: 1}
+				continue
+			}
+
+			if err != nil {
+				err = toAPIError(err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "stream error")
+				streamErr = err
+				yield(gai.MessagePart{}, err)
+			}
+			return
+		}
+	})
+
+	res.Meta = meta
+
+	return res, nil
+}
+
+// setFinishReasonAttributes on the span, with the finish reason from the API and the mapped one for legacy attributes.
+func (c *ChatCompleter) setFinishReasonAttributes(span trace.Span, reason string, mapped gai.ChatCompleteFinishReason) {
+	span.SetAttributes(genAIResponseFinishReasons.StringSlice([]string{reason}))
+	if c.legacySpanAttributes {
+		span.SetAttributes(attribute.String("ai.finish_reason", string(mapped)))
+	}
+}
+
+// NewParams converts a [gai.ChatCompleteRequest] to request parameters for this chat completer's model,
+// without sending anything, for example to preview or log a request.
+// It's the conversion used by [ChatCompleter.ChatComplete] and [ChatCompleter.WriteBatchInput].
+// Options from the context, such as [WithPromptCache] and budgets, and stream options are added by ChatComplete.
+// It returns an error wrapping [ErrUnsupported] for message roles and part types that can't be converted.
+func (c *ChatCompleter) NewParams(req gai.ChatCompleteRequest) (openai.ChatCompletionNewParams, error) {
 	var messages []openai.ChatCompletionMessageParamUnion
 
 	if req.System != nil {
 		messages = append(messages, openai.SystemMessage(*req.System))
-		span.SetAttributes(
-			attribute.Bool("ai.has_system_prompt", true),
-			attribute.String("ai.system_prompt", *req.System),
-		)
+	}
+
+	// Without json_schema support, the schema is given to the model in the prompt instead
+	if req.ResponseSchema != nil && c.compatibility.NoJSONSchema {
+		schema, err := json.Marshal(schemaToJSONObject(normalizeToolSchema(req.ResponseSchema)))
+		if err != nil {
+			return openai.ChatCompletionNewParams{}, errors.Wrap(err, "error encoding response schema")
+		}
+		messages = append(messages, openai.SystemMessage("Respond with JSON that matches this JSON schema: "+string(schema)))
 	}
 
 	for _, m := range req.Messages {
@@ -94,7 +455,7 @@ func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRe
 					continue
 
 				default:
-					panic("not implemented")
+					return openai.ChatCompletionNewParams{}, errors.Newf("%w: %v part in %v message", ErrUnsupported, part.Type, m.Role)
 				}
 			}
 
@@ -138,7 +499,7 @@ func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRe
 					continue
 
 				default:
-					panic("not implemented")
+					return openai.ChatCompletionNewParams{}, errors.Newf("%w: %v part in %v message", ErrUnsupported, part.Type, m.Role)
 				}
 			}
 
@@ -147,12 +508,11 @@ func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRe
 			}
 
 		default:
-			panic("unknown role " + m.Role)
+			return openai.ChatCompletionNewParams{}, errors.Newf("%w: message role %v", ErrUnsupported, m.Role)
 		}
 	}
 
 	var tools []openai.ChatCompletionToolParam
-	var toolNames []string
 	for _, tool := range req.Tools {
 		tools = append(tools, openai.ChatCompletionToolParam{
 			Function: openai.FunctionDefinitionParam{
@@ -164,29 +524,27 @@ func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRe
 				},
 			},
 		})
-		toolNames = append(toolNames, tool.Name)
 	}
-	sort.Strings(toolNames)
-	span.SetAttributes(
-		attribute.Int("ai.tool_count", len(tools)),
-		attribute.StringSlice("ai.tools", toolNames),
-	)
 
 	params := openai.ChatCompletionNewParams{
 		Messages: messages,
 		Model:    openai.ChatModel(c.model),
 		Tools:    tools,
-		StreamOptions: openai.ChatCompletionStreamOptionsParam{
-			IncludeUsage: openai.Bool(true),
-		},
 	}
 
+	c.promptCache.apply(&params)
+
 	if req.Temperature != nil {
 		params.Temperature = openai.Opt(req.Temperature.Float64())
-		span.SetAttributes(attribute.Float64("ai.temperature", req.Temperature.Float64()))
 	}
 
-	if req.ResponseSchema != nil {
+	if req.ResponseSchema != nil && c.compatibility.NoJSONSchema {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+		}
+	}
+
+	if req.ResponseSchema != nil && !c.compatibility.NoJSONSchema {
 		normalized := normalizeToolSchema(req.ResponseSchema)
 		jsonSchemaObject := schemaToJSONObject(normalized)
 		jsonSchema := shared.ResponseFormatJSONSchemaJSONSchemaParam{
@@ -203,96 +561,9 @@ func (c *ChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRe
 				JSONSchema: jsonSchema,
 			},
 		}
-
-		span.SetAttributes(attribute.Bool("ai.has_response_schema", true))
 	}
 
-	stream := c.Client.Chat.Completions.NewStreaming(ctx, params)
-
-	meta := &gai.ChatCompleteResponseMetadata{}
-
-	res := gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
-		defer span.End()
-
-		defer func() {
-			if err := stream.Close(); err != nil {
-				c.log.Info("Error closing stream", "error", err)
-			}
-		}()
-
-		var acc openai.ChatCompletionAccumulator
-		for stream.Next() {
-			chunk := stream.Current()
-			acc.AddChunk(chunk)
-
-			if len(chunk.Choices) > 0 {
-				if reason := chunk.Choices[0].FinishReason; reason != "" {
-					mapped := mapChatFinishReason(reason)
-					if meta.FinishReason == nil || *meta.FinishReason != mapped {
-						meta.FinishReason = gai.Ptr(mapped)
-					}
-					span.SetAttributes(attribute.String("ai.finish_reason", string(mapped)))
-				}
-			}
-
-			if _, ok := acc.JustFinishedContent(); !ok {
-				if toolCall, ok := acc.JustFinishedToolCall(); ok {
-					if !yield(gai.ToolCallPart(toolCall.ID, toolCall.Name, json.RawMessage(toolCall.Arguments)), nil) {
-						return
-					}
-					continue
-				}
-
-				if refusal, ok := acc.JustFinishedRefusal(); ok {
-					err := fmt.Errorf("refusal: %v", refusal)
-					meta.FinishReason = gai.Ptr(gai.ChatCompleteFinishReasonRefusal)
-					span.SetAttributes(attribute.String("ai.finish_reason", string(gai.ChatCompleteFinishReasonRefusal)))
-					span.RecordError(err)
-					span.SetStatus(codes.Error, "model refused request")
-					yield(gai.MessagePart{}, err)
-					return
-				}
-
-				if len(chunk.Choices) > 0 {
-					if !yield(gai.TextMessagePart(chunk.Choices[0].Delta.Content), nil) {
-						return
-					}
-				}
-			}
-
-			if chunk.Usage.PromptTokens == 0 {
-				continue
-			}
-
-			meta.Usage = gai.ChatCompleteResponseUsage{
-				PromptTokens:     int(chunk.Usage.PromptTokens),
-				CompletionTokens: int(chunk.Usage.CompletionTokens),
-			}
-			span.SetAttributes(
-				attribute.Int("ai.prompt_tokens", int(chunk.Usage.PromptTokens)),
-				attribute.Int("ai.completion_tokens", int(chunk.Usage.CompletionTokens)),
-				attribute.Int("ai.total_tokens", int(chunk.Usage.TotalTokens)),
-			)
-		}
-
-		if meta.FinishReason == nil && len(acc.Choices) > 0 {
-			if reason := acc.Choices[0].FinishReason; reason != "" {
-				mapped := mapChatFinishReason(reason)
-				meta.FinishReason = gai.Ptr(mapped)
-				span.SetAttributes(attribute.String("ai.finish_reason", string(mapped)))
-			}
-		}
-
-		if err := stream.Err(); err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "stream error")
-			yield(gai.MessagePart{}, err)
-		}
-	})
-
-	res.Meta = meta
-
-	return res, nil
+	return params, nil
 }
 
 // normalizeToolSchemaProperties recursively normalizes schema properties for OpenAI compatibility
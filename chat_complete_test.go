@@ -1,16 +1,21 @@
 package openai_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"maragu.dev/env"
 	"maragu.dev/gai"
 	"maragu.dev/gai/tools"
 	"maragu.dev/is"
 
 	openai "maragu.dev/gai-openai"
+	"maragu.dev/gai-openai/openaitest"
 )
 
 func TestChatCompleter_ChatComplete(t *testing.T) {
@@ -313,6 +318,194 @@ func TestChatCompleter_ChatComplete(t *testing.T) {
 	})
 }
 
+func TestChatCompleter_NewParams(t *testing.T) {
+	weatherTool := gai.Tool{
+		Name:        "get_weather",
+		Description: "Get the weather for a location.",
+		Schema: gai.ToolSchema{
+			Properties: map[string]*gai.Schema{
+				"location": {Type: gai.SchemaTypeString, Description: "The city and country."},
+				"unit":     {Type: gai.SchemaTypeString, Enum: []string{"celsius", "fahrenheit"}},
+				"days":     {Type: gai.SchemaTypeInteger, Minimum: gai.Ptr(1.0), Maximum: gai.Ptr(7.0)},
+				"include":  {Type: gai.SchemaTypeArray, Items: &gai.Schema{Type: gai.SchemaTypeString}},
+				"options": {
+					Type: gai.SchemaTypeObject,
+					Properties: map[string]*gai.Schema{
+						"hourly": {Type: gai.SchemaTypeBoolean},
+					},
+				},
+			},
+		},
+	}
+
+	responseSchema := &gai.Schema{
+		Title:       "Recommendation",
+		Description: "A book recommendation.",
+		Type:        gai.SchemaTypeObject,
+		Properties: map[string]*gai.Schema{
+			"title":  {Type: gai.SchemaTypeString},
+			"year":   {Type: gai.SchemaTypeInteger},
+			"rating": {Type: gai.SchemaTypeNumber},
+			"tags":   {Type: gai.SchemaTypeArray, Items: &gai.Schema{Type: gai.SchemaTypeString}},
+			"author": {
+				Type: gai.SchemaTypeObject,
+				Properties: map[string]*gai.Schema{
+					"name": {Type: gai.SchemaTypeString},
+				},
+				Required: []string{"name"},
+			},
+			"series": {AnyOf: []*gai.Schema{{Type: gai.SchemaTypeString}, {Type: gai.SchemaTypeNull}}},
+		},
+		Required: []string{"title", "year", "rating", "tags", "author", "series"},
+	}
+
+	toolCallMessage := func(parts ...gai.MessagePart) gai.Message {
+		return gai.Message{Role: gai.MessageRoleModel, Parts: parts}
+	}
+
+	tests := []struct {
+		name string
+		opts openai.NewChatCompleterOptions
+		comp *openai.Compatibility
+		req  gai.ChatCompleteRequest
+	}{
+		{
+			name: "user_text",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+			},
+		},
+		{
+			name: "user_text_parts",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{
+					{Role: gai.MessageRoleUser, Parts: []gai.MessagePart{gai.TextMessagePart("Hi!"), gai.TextMessagePart("How are you?")}},
+				},
+			},
+		},
+		{
+			name: "system_prompt_and_temperature",
+			req: gai.ChatCompleteRequest{
+				Messages:    []gai.Message{gai.NewUserTextMessage("Hi!")},
+				System:      gai.Ptr("You always respond in French."),
+				Temperature: gai.Ptr(gai.Temperature(0.5)),
+			},
+		},
+		{
+			name: "conversation",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{
+					gai.NewUserTextMessage("Hi!"),
+					gai.NewModelTextMessage("Hello! How can I help you today?"),
+					gai.NewUserTextMessage("What does AI stand for?"),
+				},
+			},
+		},
+		{
+			name: "tool_round_trip",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{
+					gai.NewUserTextMessage("What's the weather in Copenhagen?"),
+					toolCallMessage(gai.ToolCallPart("call_1", "get_weather", json.RawMessage(`{"location":"Copenhagen, Denmark"}`))),
+					gai.NewUserToolResultMessage(gai.ToolResult{ID: "call_1", Name: "get_weather", Content: "Sunny, 20 degrees."}),
+				},
+				Tools: []gai.Tool{weatherTool},
+			},
+		},
+		{
+			name: "tool_calls_with_text",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{
+					gai.NewUserTextMessage("What's the weather in Copenhagen and Aarhus?"),
+					toolCallMessage(
+						gai.TextMessagePart("Let me check."),
+						gai.ToolCallPart("call_1", "get_weather", json.RawMessage(`{"location":"Copenhagen, Denmark"}`)),
+						gai.ToolCallPart("call_2", "get_weather", json.RawMessage(`{"location":"Aarhus, Denmark"}`)),
+					),
+					{Role: gai.MessageRoleUser, Parts: []gai.MessagePart{
+						gai.ToolResultPart("call_1", "get_weather", "Sunny, 20 degrees.", nil),
+						gai.ToolResultPart("call_2", "get_weather", "", errors.New("location not found")),
+						gai.TextMessagePart("Also, should I bring an umbrella?"),
+					}},
+				},
+				Tools: []gai.Tool{weatherTool},
+			},
+		},
+		{
+			name: "tool_without_args",
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{gai.NewUserTextMessage("What time is it?")},
+				Tools:    []gai.Tool{{Name: "get_time", Description: "Get the current time."}},
+			},
+		},
+		{
+			name: "response_schema",
+			req: gai.ChatCompleteRequest{
+				Messages:       []gai.Message{gai.NewUserTextMessage("Recommend a book.")},
+				ResponseSchema: responseSchema,
+			},
+		},
+		{
+			name: "response_schema_without_json_schema",
+			comp: &openai.Compatibility{NoJSONSchema: true},
+			req: gai.ChatCompleteRequest{
+				Messages:       []gai.Message{gai.NewUserTextMessage("Recommend a book.")},
+				ResponseSchema: responseSchema,
+				System:         gai.Ptr("You are a librarian."),
+			},
+		},
+		{
+			name: "prompt_cache",
+			opts: openai.NewChatCompleterOptions{
+				PromptCache: openai.PromptCacheOptions{Key: "librarian", Retention: openai.PromptCacheRetention24h},
+			},
+			req: gai.ChatCompleteRequest{
+				Messages: []gai.Message{gai.NewUserTextMessage("Recommend a book.")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := openai.NewClient(openai.NewClientOptions{Key: "test", Compatibility: test.comp})
+			test.opts.Model = openai.ChatCompleteModelGPT4oMini
+			cc := c.NewChatCompleter(test.opts)
+
+			params, err := cc.NewParams(test.req)
+			is.NotError(t, err)
+
+			requireGolden(t, filepath.Join("testdata", "golden", "new_params", test.name+".json"), params)
+		})
+	}
+
+	t.Run("errors on unsupported part types and roles", func(t *testing.T) {
+		cc := openai.NewClient(openai.NewClientOptions{Key: "test"}).NewChatCompleter(openai.NewChatCompleterOptions{})
+
+		_, err := cc.NewParams(gai.ChatCompleteRequest{
+			Messages: []gai.Message{
+				{Role: gai.MessageRoleUser, Parts: []gai.MessagePart{{Type: gai.MessagePartTypeData, MIMEType: "image/png"}}},
+			},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+
+		_, err = cc.NewParams(gai.ChatCompleteRequest{
+			Messages: []gai.Message{
+				toolCallMessage(gai.ToolResultPart("call_1", "get_weather", "Sunny.", nil)),
+			},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+
+		_, err = cc.NewParams(gai.ChatCompleteRequest{
+			Messages: []gai.Message{{Role: "system", Parts: []gai.MessagePart{gai.TextMessagePart("Hi!")}}},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+	})
+}
+
+func TestChatCompleter_Conformance(t *testing.T) {
+	openaitest.RunChatCompleterConformance(t, newChatCompleter(t))
+}
+
 func newChatCompleter(t *testing.T) *openai.ChatCompleter {
 	c := newClient(t)
 	cc := c.NewChatCompleter(openai.NewChatCompleterOptions{
@@ -346,3 +539,25 @@ func requireContainsAny(t *testing.T, got string, want ...string) {
 
 	t.Fatalf("expected output %q to contain one of %v", got, want)
 }
+
+// requireGolden compares v encoded as indented JSON with the golden file at path.
+// Set OPENAI_UPDATE_GOLDEN=true to write the golden file instead.
+func requireGolden(t *testing.T, path string, v any) {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	is.NotError(t, err)
+	var indented bytes.Buffer
+	is.NotError(t, json.Indent(&indented, b, "", "  "))
+	indented.WriteString("\n")
+
+	if env.GetBoolOrDefault("OPENAI_UPDATE_GOLDEN", false) {
+		is.NotError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		is.NotError(t, os.WriteFile(path, indented.Bytes(), 0o644))
+		return
+	}
+
+	golden, err := os.ReadFile(path)
+	is.NotError(t, err, "golden file missing, set OPENAI_UPDATE_GOLDEN=true to create it")
+	is.Equal(t, string(golden), indented.String())
+}
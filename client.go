@@ -1,22 +1,85 @@
 package openai
 
 import (
+	"context"
+	"io"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Client struct {
-	Client openai.Client
-	log    *slog.Logger
+	Client                   openai.Client
+	compatibility            Compatibility
+	contentCapture           contentCapture
+	defaultChatCompleteModel ChatCompleteModel
+	defaultEmbedDimensions   int
+	defaultEmbedModel        EmbedModel
+	legacySpanAttributes     bool
+	log                      *slog.Logger
+	metrics                  *metrics
+	rateLimiter              *rateLimiter
+	retrier                  *retrier
+	system                   string
+	tracer                   trace.Tracer
+	usageAccumulator         *UsageAccumulator
 }
 
 type NewClientOptions struct {
+	// Azure configures the client for Azure OpenAI. BaseURL, Key, and Provider must not be set when using it.
+	Azure *AzureOptions
+	// BaseURL of the API. Defaults to the base URL of the Provider.
 	BaseURL string
-	Key     string
-	Log     *slog.Logger
+	// Compatibility overrides the compatibility preset of the Provider.
+	Compatibility *Compatibility
+	// ContentCapture configures which prompt and response content is recorded in spans. Nothing is recorded by default.
+	ContentCapture ContentCaptureOptions
+	// DefaultChatCompleteModel is used by [Client.NewChatCompleter] if no model is given.
+	DefaultChatCompleteModel ChatCompleteModel
+	// DefaultEmbedDimensions is used by [Client.NewEmbedder] if no dimensions are given.
+	DefaultEmbedDimensions int
+	// DefaultEmbedModel is used by [Client.NewEmbedder] if no model is given.
+	DefaultEmbedModel EmbedModel
+	// Headers are added to every request.
+	Headers http.Header
+	// HTTPClient to make requests with, for example for proxies and mTLS. Defaults to [http.DefaultClient].
+	HTTPClient *http.Client
+	Key        string
+	// LegacySpanAttributes also records the ai.* span attributes that were replaced by gen_ai.* semantic conventions:
+	// ai.model, ai.temperature, ai.dimensions, ai.prompt_tokens, ai.completion_tokens, ai.total_tokens,
+	// and ai.finish_reason. Other ai.* attributes, such as ai.message_count and ai.tools, have no gen_ai.* equivalent
+	// and are always recorded.
+	LegacySpanAttributes bool
+	// Log of requests and responses at debug level, and of retries. Content is only logged as allowed by ContentCapture.
+	Log *slog.Logger
+	// MeterProvider for metrics. Defaults to the global meter provider.
+	MeterProvider metric.MeterProvider
+	// Middleware intercepts every HTTP request and response, in the order given.
+	// It runs once for every retry attempt.
+	Middleware []option.Middleware
+	// Organization for the OpenAI-Organization header.
+	Organization string
+	// Project for the OpenAI-Project header.
+	Project string
+	// Provider of the OpenAI-compatible API. Defaults to OpenAI.
+	Provider  Provider
+	RateLimit RateLimitOptions
+	// RequestOptions are passed to the OpenAI SDK after all other options, so they can override them.
+	RequestOptions []option.RequestOption
+	Retry          RetryOptions
+	// Timeout for each request attempt, including reading the response body, so streams must finish within it.
+	// There is no timeout if zero.
+	Timeout time.Duration
+	// TracerProvider for spans. Defaults to the global tracer provider.
+	TracerProvider trace.TracerProvider
+	// UsageAccumulator aggregates the token usage and estimated cost of chat completions and embeddings, if set.
+	UsageAccumulator *UsageAccumulator
 }
 
 func NewClient(opts NewClientOptions) *Client {
@@ -24,7 +87,62 @@ func NewClient(opts NewClientOptions) *Client {
 		opts.Log = slog.New(slog.DiscardHandler)
 	}
 
-	var clientOpts []option.RequestOption
+	r := newRetrier(opts.Retry, opts.Log)
+
+	// Retries are handled by our own middleware instead of the SDK, so they can be configured
+	clientOpts := []option.RequestOption{
+		option.WithMaxRetries(0),
+		option.WithMiddleware(r.middleware, credentialsMiddleware),
+	}
+
+	if opts.Timeout > 0 {
+		clientOpts = append(clientOpts, option.WithMiddleware(timeoutMiddleware(opts.Timeout)))
+	}
+
+	if opts.Azure != nil {
+		if opts.BaseURL != "" || opts.Key != "" || opts.Provider != ProviderOpenAI {
+			panic("base URL, key, and provider must not be set when using azure")
+		}
+		a := newAzure(*opts.Azure)
+		clientOpts = append(clientOpts,
+			option.WithBaseURL(azureBaseURL(opts.Azure.Endpoint)),
+			option.WithMiddleware(a.middleware),
+		)
+	}
+
+	clientOpts = append(clientOpts, option.WithMiddleware(opts.Middleware...))
+
+	if opts.HTTPClient != nil {
+		clientOpts = append(clientOpts, option.WithHTTPClient(opts.HTTPClient))
+	}
+
+	for key, values := range opts.Headers {
+		for _, value := range values {
+			clientOpts = append(clientOpts, option.WithHeaderAdd(key, value))
+		}
+	}
+
+	preset, ok := providerPresets[opts.Provider]
+	if !ok {
+		panic("unknown provider " + string(opts.Provider))
+	}
+
+	if opts.Provider != ProviderOpenAI {
+		if opts.BaseURL == "" {
+			opts.BaseURL = preset.baseURL
+		}
+
+		// Don't send OpenAI credentials from the environment to other providers
+		clientOpts = append(clientOpts,
+			option.WithHeaderDel("Authorization"),
+			option.WithHeaderDel("OpenAI-Organization"),
+			option.WithHeaderDel("OpenAI-Project"),
+		)
+	}
+
+	if opts.Compatibility != nil {
+		preset.compatibility = *opts.Compatibility
+	}
 
 	if opts.BaseURL != "" {
 		if !strings.HasSuffix(opts.BaseURL, "/") {
@@ -37,8 +155,55 @@ func NewClient(opts NewClientOptions) *Client {
 		clientOpts = append(clientOpts, option.WithAPIKey(opts.Key))
 	}
 
+	if opts.Organization != "" {
+		clientOpts = append(clientOpts, option.WithOrganization(opts.Organization))
+	}
+
+	if opts.Project != "" {
+		clientOpts = append(clientOpts, option.WithProject(opts.Project))
+	}
+
+	clientOpts = append(clientOpts, opts.RequestOptions...)
+
 	return &Client{
-		Client: openai.NewClient(clientOpts...),
-		log:    opts.Log,
+		Client:                   openai.NewClient(clientOpts...),
+		compatibility:            preset.compatibility,
+		contentCapture:           newContentCapture(opts.ContentCapture),
+		defaultChatCompleteModel: opts.DefaultChatCompleteModel,
+		defaultEmbedDimensions:   opts.DefaultEmbedDimensions,
+		defaultEmbedModel:        opts.DefaultEmbedModel,
+		legacySpanAttributes:     opts.LegacySpanAttributes,
+		log:                      opts.Log,
+		metrics:                  newMetrics(opts.MeterProvider),
+		rateLimiter:              newRateLimiter(opts.RateLimit),
+		retrier:                  r,
+		system:                   genAISystemName(opts),
+		tracer:                   newTracer(opts.TracerProvider),
+		usageAccumulator:         opts.UsageAccumulator,
 	}
 }
+
+// timeoutMiddleware cancels each request attempt after the given duration.
+// The timeout covers reading the response body, so it's only cancelled when the body is closed.
+func timeoutMiddleware(d time.Duration) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		ctx, cancel := context.WithTimeout(req.Context(), d)
+		res, err := next(req.WithContext(ctx))
+		if err != nil || res == nil {
+			cancel()
+			return res, err
+		}
+		res.Body = &cancelOnCloseBody{ReadCloser: res.Body, cancel: cancel}
+		return res, nil
+	}
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
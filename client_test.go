@@ -1,22 +1,223 @@
 package openai_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/openai/openai-go/option"
 	"maragu.dev/env"
+	"maragu.dev/gai"
 	"maragu.dev/is"
 
 	openai "maragu.dev/gai-openai"
+	"maragu.dev/gai-openai/internal/cassette"
 )
 
 func TestNewClient(t *testing.T) {
 	t.Run("can create a new client with a key", func(t *testing.T) {
-		client := newClient(t)
+		client := openai.NewClient(openai.NewClientOptions{Key: "test"})
 		is.NotNil(t, client)
 	})
+
+	t.Run("can use a custom HTTP client, headers, middleware, and request options", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "corp", r.Header.Get("X-Proxy-Tenant"))
+			is.Equal(t, "yes", r.Header.Get("X-Middleware"))
+			is.Equal(t, "override", r.Header.Get("X-Request-Option"))
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		var transportCalls, middlewareCalls atomic.Int32
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			Key:     "test",
+			Headers: http.Header{"X-Proxy-Tenant": []string{"corp"}},
+			HTTPClient: &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				transportCalls.Add(1)
+				return http.DefaultTransport.RoundTrip(r)
+			})},
+			Middleware: []option.Middleware{func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+				middlewareCalls.Add(1)
+				r.Header.Set("X-Middleware", "yes")
+				return next(r)
+			}},
+			RequestOptions: []option.RequestOption{option.WithHeader("X-Request-Option", "override")},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, int32(1), transportCalls.Load())
+		is.Equal(t, int32(1), middlewareCalls.Load())
+	})
+
+	t.Run("times out each request attempt", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				time.Sleep(200 * time.Millisecond)
+				return
+			}
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			Key:     "test",
+			Retry:   openai.RetryOptions{InitialBackoff: time.Millisecond},
+			Timeout: 50 * time.Millisecond,
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("returns a timeout error when retries are disabled", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			Key:     "test",
+			Retry:   openai.RetryOptions{MaxRetries: -1},
+			Timeout: 50 * time.Millisecond,
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, errors.Is(err, context.DeadlineExceeded), "should time out")
+	})
+}
+
+func TestNewClient_Log(t *testing.T) {
+	t.Run("logs chat completion requests and responses at debug level, with captured content", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("x-request-id", "req_123")
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"}}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		var buf bytes.Buffer
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			ContentCapture: openai.ContentCaptureOptions{
+				Messages: true,
+				Redactor: openai.NewRegexpRedactor(openai.EmailPattern),
+			},
+			Key: "test",
+			Log: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			System:   gai.Ptr("You are a helpful assistant."),
+			Messages: []gai.Message{gai.NewUserTextMessage("My email is me@example.com.")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		logs := decodeLogs(t, &buf)
+		is.Equal(t, 2, len(logs))
+
+		is.Equal(t, "Chat completion request", logs[0]["msg"].(string))
+		is.Equal(t, "DEBUG", logs[0]["level"].(string))
+		is.Equal(t, "gpt-4o-mini", logs[0]["model"].(string))
+		is.Equal(t, float64(1), logs[0]["messageCount"].(float64))
+		is.Equal(t, true, logs[0]["hasSystemPrompt"].(bool))
+		messages := logs[0]["messages"].([]any)
+		is.Equal(t, 1, len(messages), "system prompt should not be captured")
+		is.Equal(t, "My email is [REDACTED].", messages[0].(map[string]any)["content"].(string))
+
+		is.Equal(t, "Chat completion response", logs[1]["msg"].(string))
+		is.Equal(t, "req_123", logs[1]["requestID"].(string))
+		is.Equal(t, "chatcmpl-1", logs[1]["responseID"].(string))
+		is.Equal(t, "stop", logs[1]["finishReason"].(string))
+		is.Equal(t, float64(10), logs[1]["promptTokens"].(float64))
+		is.Equal(t, float64(2), logs[1]["completionTokens"].(float64))
+		_, ok := logs[1]["output"]
+		is.True(t, !ok, "output should not be captured")
+	})
+
+	t.Run("logs embedding requests and responses at debug level", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("x-request-id", "req_123")
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		var buf bytes.Buffer
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			Key:     "test",
+			Log:     slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		logs := decodeLogs(t, &buf)
+		is.Equal(t, 2, len(logs))
+		is.Equal(t, "Embedding request", logs[0]["msg"].(string))
+		is.Equal(t, float64(3), logs[0]["inputLength"].(float64))
+		is.Equal(t, "Embedding response", logs[1]["msg"].(string))
+		is.Equal(t, "req_123", logs[1]["requestID"].(string))
+		is.Equal(t, float64(1), logs[1]["promptTokens"].(float64))
+	})
+
+	t.Run("does not log requests and responses at info level", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		var buf bytes.Buffer
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL: s.URL,
+			Key:     "test",
+			Log:     slog.New(slog.NewJSONHandler(&buf, nil)),
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, 0, buf.Len())
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
 }
 
+// newClient for tests against the API. If the test has a cassette in testdata/cassettes, it's replayed instead.
+// Set OPENAI_RECORD=true to record the cassette from the API.
+// Without a cassette or an OPENAI_KEY, the test is skipped.
 func newClient(t *testing.T) *openai.Client {
 	t.Helper()
 
@@ -24,10 +225,37 @@ func newClient(t *testing.T) *openai.Client {
 
 	log := slog.New(slog.NewTextHandler(&tWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
-	return openai.NewClient(openai.NewClientOptions{
+	opts := openai.NewClientOptions{
 		Key: env.GetStringOrDefault("OPENAI_KEY", ""),
 		Log: log,
-	})
+	}
+
+	path := filepath.Join("testdata", "cassettes", t.Name()+".json")
+	_, err := os.Stat(path)
+
+	switch {
+	case env.GetBoolOrDefault("OPENAI_RECORD", false):
+		transport, err := cassette.New(path, cassette.ModeRecord, http.DefaultTransport)
+		is.NotError(t, err)
+		t.Cleanup(func() {
+			if !t.Failed() {
+				is.NotError(t, transport.Save())
+			}
+		})
+		opts.HTTPClient = &http.Client{Transport: transport}
+
+	case err == nil:
+		transport, err := cassette.New(path, cassette.ModeReplay, nil)
+		is.NotError(t, err)
+		opts.HTTPClient = &http.Client{Transport: transport}
+		opts.Key = "test"
+		opts.Retry = openai.RetryOptions{MaxRetries: -1}
+
+	case opts.Key == "":
+		t.Skipf("no cassette at %v and no OPENAI_KEY to test against the API", path)
+	}
+
+	return openai.NewClient(opts)
 }
 
 type tWriter struct {
@@ -38,3 +266,16 @@ func (w *tWriter) Write(p []byte) (n int, err error) {
 	w.t.Log(string(p))
 	return len(p), nil
 }
+
+func decodeLogs(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+
+	var logs []map[string]any
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var log map[string]any
+		is.NotError(t, dec.Decode(&log))
+		logs = append(logs, log)
+	}
+	return logs
+}
@@ -0,0 +1,49 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Credentials override the API key, organization, and project of a [Client] for a single request.
+// Empty fields are not overridden.
+type Credentials struct {
+	Key          string
+	Organization string
+	Project      string
+}
+
+type credentialsContextKey struct{}
+
+// WithCredentials returns a context that makes requests using it, such as [ChatCompleter.ChatComplete]
+// and [Embedder.Embed], use the given credentials instead of the ones the [Client] was created with.
+// This is useful for using a different API key or project per tenant with a shared [Client].
+func WithCredentials(ctx context.Context, c Credentials) context.Context {
+	return context.WithValue(ctx, credentialsContextKey{}, c)
+}
+
+func credentialsFromContext(ctx context.Context) (Credentials, bool) {
+	c, ok := ctx.Value(credentialsContextKey{}).(Credentials)
+	return c, ok
+}
+
+// credentialsMiddleware sets the headers for the credentials in the request context, if any.
+func credentialsMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	c, ok := credentialsFromContext(req.Context())
+	if !ok {
+		return next(req)
+	}
+
+	if c.Key != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Key)
+	}
+	if c.Organization != "" {
+		req.Header.Set("OpenAI-Organization", c.Organization)
+	}
+	if c.Project != "" {
+		req.Header.Set("OpenAI-Project", c.Project)
+	}
+	return next(req)
+}
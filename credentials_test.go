@@ -0,0 +1,49 @@
+package openai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestWithCredentials(t *testing.T) {
+	t.Run("overrides the client key and project for a request", func(t *testing.T) {
+		var headers []http.Header
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			headers = append(headers, r.Header.Clone())
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:      s.URL,
+			Key:          "default-key",
+			Organization: "org-default",
+			Project:      "proj-default",
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		ctx := openai.WithCredentials(t.Context(), openai.Credentials{Key: "tenant-key", Project: "proj-tenant"})
+		_, err = e.Embed(ctx, gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		is.Equal(t, 2, len(headers))
+
+		is.Equal(t, "Bearer default-key", headers[0].Get("Authorization"))
+		is.Equal(t, "org-default", headers[0].Get("OpenAI-Organization"))
+		is.Equal(t, "proj-default", headers[0].Get("OpenAI-Project"))
+
+		is.Equal(t, "Bearer tenant-key", headers[1].Get("Authorization"))
+		is.Equal(t, "org-default", headers[1].Get("OpenAI-Organization"))
+		is.Equal(t, "proj-tenant", headers[1].Get("OpenAI-Project"))
+	})
+}
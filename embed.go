@@ -2,10 +2,13 @@ package openai
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/openai/openai-go"
-	"go.opentelemetry.io/otel"
+	"github.com/openai/openai-go/option"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -21,11 +24,17 @@ const (
 )
 
 type Embedder struct {
-	Client     openai.Client
-	dimensions int
-	log        *slog.Logger
-	model      EmbedModel
-	tracer     trace.Tracer
+	Client               openai.Client
+	compatibility        Compatibility
+	dimensions           int
+	legacySpanAttributes bool
+	log                  *slog.Logger
+	metrics              *metrics
+	model                EmbedModel
+	rateLimiter          *rateLimiter
+	system               string
+	tracer               trace.Tracer
+	usageAccumulator     *UsageAccumulator
 }
 
 type NewEmbedderOptions struct {
@@ -34,68 +43,134 @@ type NewEmbedderOptions struct {
 }
 
 func (c *Client) NewEmbedder(opts NewEmbedderOptions) *Embedder {
+	if opts.Model == "" {
+		opts.Model = c.defaultEmbedModel
+	}
+	if opts.Dimensions == 0 {
+		opts.Dimensions = c.defaultEmbedDimensions
+	}
+
 	if opts.Dimensions <= 0 {
 		panic("dimensions must be greater than 0")
 	}
 
-	switch opts.Model {
-	case EmbedModelTextEmbedding3Large:
-		if opts.Dimensions > 3072 {
-			panic("dimensions must be less than or equal to 3072")
-		}
-	case EmbedModelTextEmbedding3Small:
-		if opts.Dimensions > 1536 {
-			panic("dimensions must be less than or equal to 1536")
-		}
+	if capabilities, ok := GetEmbedModelCapabilities(opts.Model); ok && opts.Dimensions > capabilities.MaxDimensions {
+		panic(fmt.Sprintf("dimensions must be less than or equal to %v", capabilities.MaxDimensions))
 	}
 
 	return &Embedder{
-		Client:     c.Client,
-		dimensions: opts.Dimensions,
-		log:        c.log,
-		model:      opts.Model,
-		tracer:     otel.Tracer("maragu.dev/gai-openai"),
+		Client:               c.Client,
+		compatibility:        c.compatibility,
+		dimensions:           opts.Dimensions,
+		legacySpanAttributes: c.legacySpanAttributes,
+		log:                  c.log,
+		metrics:              c.metrics,
+		model:                opts.Model,
+		rateLimiter:          c.rateLimiter,
+		system:               c.system,
+		tracer:               c.tracer,
+		usageAccumulator:     c.usageAccumulator,
 	}
 }
 
 // Embed satisfies [gai.Embedder].
 func (e *Embedder) Embed(ctx context.Context, req gai.EmbedRequest) (gai.EmbedResponse[float64], error) {
-	ctx, span := e.tracer.Start(ctx, "openai.embed",
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(
+	attrs := append(genAIAttributes("embeddings", e.system, string(e.model)),
+		genAIRequestEncodingFormats.StringSlice([]string{"float"}),
+	)
+	if !e.compatibility.NoDimensions {
+		attrs = append(attrs, genAIEmbeddingsDimensionCount.Int(e.dimensions))
+	}
+	if e.legacySpanAttributes {
+		attrs = append(attrs,
 			attribute.String("ai.model", string(e.model)),
 			attribute.Int("ai.dimensions", e.dimensions),
-		),
+		)
+	}
+
+	ctx, span := e.tracer.Start(ctx, "embeddings "+string(e.model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
 	)
 	defer span.End()
 
+	requestMetrics := e.metrics.start("embeddings", string(e.model))
+
 	v := gai.ReadAllString(req.Input)
 	span.SetAttributes(attribute.Int("ai.input_length", len(v)))
 
-	res, err := e.Client.Embeddings.New(ctx, openai.EmbeddingNewParams{
-		Input:          openai.EmbeddingNewParamsInputUnion{OfString: openai.Opt(v)},
-		Model:          openai.EmbeddingModel(e.model),
-		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
-		Dimensions:     openai.Opt(int64(e.dimensions)),
-	})
+	estimatedTokens := estimateTokensFromChars(len(v))
+
+	requestBudget := budgetFromContext(ctx)
+	if _, err := requestBudget.check(string(e.model), estimatedTokens); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "budget exceeded")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		requestMetrics.end(ctx, 0, 0, err)
+		return gai.EmbedResponse[float64]{}, err
+	}
+
+	if err := e.rateLimiter.wait(ctx, estimatedTokens); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "rate limited")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		requestMetrics.end(ctx, 0, 0, err)
+		return gai.EmbedResponse[float64]{}, err
+	}
+
+	e.log.DebugContext(ctx, "Embedding request", "model", e.model, "dimensions", e.dimensions, "inputLength", len(v))
+
+	start := time.Now()
+	var httpRes *http.Response
+	// Retries of the request are charged to the rate limiter too
+	requestCtx := withRateLimit(ctx, e.rateLimiter, estimatedTokens)
+	res, err := e.Client.Embeddings.New(requestCtx, e.newParams(v), option.WithResponseInto(&httpRes))
 	if err != nil {
+		err = toAPIError(err)
+		e.usageAccumulator.record(ctx, Usage{Requests: 1})
+		e.log.DebugContext(ctx, "Embedding response", "model", e.model, "requestID", requestIDOf(httpRes),
+			"duration", time.Since(start), "error", err)
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "embedding request failed")
+		span.SetAttributes(errorTypeKey.String(errorType(err)))
+		requestMetrics.end(ctx, 0, 0, err)
 		return gai.EmbedResponse[float64]{}, errors.Wrap(err, "error embedding")
 	}
 	if len(res.Data) == 0 {
 		err := errors.New("no embeddings returned")
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "no embeddings in response")
+		requestMetrics.end(ctx, int(res.Usage.PromptTokens), 0, err)
 		return gai.EmbedResponse[float64]{}, err
 	}
 
+	usage := Usage{Requests: 1, PromptTokens: int(res.Usage.PromptTokens)}
+	if cost, ok := EstimateCost(string(e.model), usage); ok {
+		usage.Cost = cost
+		span.SetAttributes(genAIUsageEstimatedCostUSD.Float64(cost))
+	}
+	e.usageAccumulator.record(ctx, usage)
+	requestBudget.record(usage)
+	storeUsageInto(ctx, usage)
+
+	e.log.DebugContext(ctx, "Embedding response", "model", e.model, "requestID", requestIDOf(httpRes),
+		"promptTokens", res.Usage.PromptTokens, "cost", usage.Cost, "duration", time.Since(start))
+
+	requestMetrics.end(ctx, int(res.Usage.PromptTokens), 0, nil)
+
+	e.rateLimiter.reconcile(estimatedTokens, int(res.Usage.TotalTokens))
+
+	span.SetAttributes(genAIResponseModel.String(res.Model))
+
 	// Record token usage if available
 	if res.Usage.PromptTokens > 0 {
-		span.SetAttributes(
-			attribute.Int("ai.prompt_tokens", int(res.Usage.PromptTokens)),
-			attribute.Int("ai.total_tokens", int(res.Usage.TotalTokens)),
-		)
+		span.SetAttributes(genAIUsageInputTokens.Int(int(res.Usage.PromptTokens)))
+		if e.legacySpanAttributes {
+			span.SetAttributes(
+				attribute.Int("ai.prompt_tokens", int(res.Usage.PromptTokens)),
+				attribute.Int("ai.total_tokens", int(res.Usage.TotalTokens)),
+			)
+		}
 	}
 
 	return gai.EmbedResponse[float64]{
@@ -103,4 +178,18 @@ func (e *Embedder) Embed(ctx context.Context, req gai.EmbedRequest) (gai.EmbedRe
 	}, nil
 }
 
+// newParams for embedding the given input with this embedder's model and dimensions.
+// It's shared between embedding and batches.
+func (e *Embedder) newParams(input string) openai.EmbeddingNewParams {
+	params := openai.EmbeddingNewParams{
+		Input:          openai.EmbeddingNewParamsInputUnion{OfString: openai.Opt(input)},
+		Model:          openai.EmbeddingModel(e.model),
+		EncodingFormat: openai.EmbeddingNewParamsEncodingFormatFloat,
+	}
+	if !e.compatibility.NoDimensions {
+		params.Dimensions = openai.Opt(int64(e.dimensions))
+	}
+	return params
+}
+
 var _ gai.Embedder[float64] = (*Embedder)(nil)
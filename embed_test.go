@@ -8,6 +8,7 @@ import (
 	"maragu.dev/is"
 
 	openai "maragu.dev/gai-openai"
+	"maragu.dev/gai-openai/openaitest"
 )
 
 func TestEmbedder_Embed(t *testing.T) {
@@ -29,3 +30,14 @@ func TestEmbedder_Embed(t *testing.T) {
 		is.Equal(t, 1536, len(res.Embedding))
 	})
 }
+
+func TestEmbedder_Conformance(t *testing.T) {
+	c := newClient(t)
+
+	e := c.NewEmbedder(openai.NewEmbedderOptions{
+		Model:      openai.EmbedModelTextEmbedding3Small,
+		Dimensions: 1536,
+	})
+
+	openaitest.RunEmbedderConformance(t, e)
+}
@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"maragu.dev/errors"
+)
+
+// NewClientFromEnv creates a [Client] from environment variables, set on top of the given options.
+// Empty variables are treated as unset. The variables are:
+//
+//   - OPENAI_API_KEY: API key, falling back to OPENAI_KEY. Required for the OpenAI provider, unless opts has a key.
+//     Ignored if opts has Azure options.
+//   - OPENAI_BASE_URL: base URL of the API, like "https://api.openai.com/v1/". Must not be set if opts has Azure options.
+//   - OPENAI_ORG_ID: organization for the OpenAI-Organization header.
+//   - OPENAI_PROJECT_ID: project for the OpenAI-Project header.
+//   - OPENAI_PROVIDER: provider preset, like "ollama". See [Provider]. Must not be set if opts has Azure options.
+//   - OPENAI_CHAT_MODEL: default model for [Client.NewChatCompleter].
+//   - OPENAI_EMBED_MODEL: default model for [Client.NewEmbedder].
+//   - OPENAI_EMBED_DIMENSIONS: default dimensions for [Client.NewEmbedder], a positive integer.
+//     Defaults to the max dimensions of the embed model, and is required if the model is unknown.
+//   - OPENAI_TIMEOUT: timeout for each request attempt, a positive duration like "30s".
+//   - OPENAI_MAX_RETRIES: max retries after the first attempt, an integer. Zero or negative disables retries.
+//   - OPENAI_REQUESTS_PER_MINUTE: client-side request rate limit, a non-negative integer.
+//   - OPENAI_TOKENS_PER_MINUTE: client-side token rate limit, a non-negative integer.
+//
+// The returned error lists every missing or malformed variable.
+func NewClientFromEnv(opts NewClientOptions) (*Client, error) {
+	var errs []error
+
+	// Azure has its own authentication, so an OpenAI key in the environment doesn't apply
+	if opts.Azure == nil {
+		if v := os.Getenv("OPENAI_API_KEY"); v != "" {
+			opts.Key = v
+		} else if v := os.Getenv("OPENAI_KEY"); v != "" {
+			opts.Key = v
+		}
+	}
+
+	if v := os.Getenv("OPENAI_BASE_URL"); v != "" {
+		if opts.Azure != nil {
+			errs = append(errs, errors.New("OPENAI_BASE_URL is malformed: must not be set when using azure"))
+		} else if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, errors.New("OPENAI_BASE_URL is malformed: must be an absolute http or https URL"))
+		} else {
+			opts.BaseURL = v
+		}
+	}
+
+	if v := os.Getenv("OPENAI_ORG_ID"); v != "" {
+		opts.Organization = v
+	}
+
+	if v := os.Getenv("OPENAI_PROJECT_ID"); v != "" {
+		opts.Project = v
+	}
+
+	if v := os.Getenv("OPENAI_PROVIDER"); v != "" {
+		if opts.Azure != nil {
+			errs = append(errs, errors.New("OPENAI_PROVIDER is malformed: must not be set when using azure"))
+		} else if _, ok := providerPresets[Provider(v)]; !ok {
+			errs = append(errs, errors.Newf("OPENAI_PROVIDER is malformed: unknown provider %v", v))
+		} else {
+			opts.Provider = Provider(v)
+		}
+	}
+
+	if v := os.Getenv("OPENAI_CHAT_MODEL"); v != "" {
+		opts.DefaultChatCompleteModel = ChatCompleteModel(v)
+	}
+
+	if v := os.Getenv("OPENAI_EMBED_MODEL"); v != "" {
+		opts.DefaultEmbedModel = EmbedModel(v)
+	}
+
+	if v, err := getEnvInt("OPENAI_EMBED_DIMENSIONS", 1); err != nil {
+		errs = append(errs, err)
+	} else {
+		if v != nil {
+			opts.DefaultEmbedDimensions = *v
+		}
+		if err := checkEmbedDimensions(&opts); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if v := os.Getenv("OPENAI_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			errs = append(errs, errors.New("OPENAI_TIMEOUT is malformed: must be a positive duration like 30s"))
+		} else {
+			opts.Timeout = d
+		}
+	}
+
+	if v := os.Getenv("OPENAI_MAX_RETRIES"); v != "" {
+		if i, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, errors.New("OPENAI_MAX_RETRIES is malformed: must be an integer"))
+		} else {
+			// Zero means the default in RetryOptions, so use a negative number to disable retries
+			if i == 0 {
+				i = -1
+			}
+			opts.Retry.MaxRetries = i
+		}
+	}
+
+	if v, err := getEnvInt("OPENAI_REQUESTS_PER_MINUTE", 0); err != nil {
+		errs = append(errs, err)
+	} else if v != nil {
+		opts.RateLimit.RequestsPerMinute = *v
+	}
+
+	if v, err := getEnvInt("OPENAI_TOKENS_PER_MINUTE", 0); err != nil {
+		errs = append(errs, err)
+	} else if v != nil {
+		opts.RateLimit.TokensPerMinute = *v
+	}
+
+	if opts.Key == "" && opts.Provider == ProviderOpenAI && opts.Azure == nil {
+		errs = append(errs, errors.New("OPENAI_API_KEY is missing"))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Wrap(errors.Join(errs...), "invalid environment")
+	}
+
+	return NewClient(opts), nil
+}
+
+// checkEmbedDimensions for the default embed model, so [Client.NewEmbedder] doesn't panic later.
+// The dimensions default to the max dimensions of a known model.
+func checkEmbedDimensions(opts *NewClientOptions) error {
+	if opts.DefaultEmbedModel == "" {
+		return nil
+	}
+
+	capabilities, ok := GetEmbedModelCapabilities(opts.DefaultEmbedModel)
+	switch {
+	case opts.DefaultEmbedDimensions == 0 && ok:
+		opts.DefaultEmbedDimensions = capabilities.MaxDimensions
+	case opts.DefaultEmbedDimensions == 0:
+		return errors.Newf("OPENAI_EMBED_DIMENSIONS is missing: required for unknown embed model %v", opts.DefaultEmbedModel)
+	case ok && opts.DefaultEmbedDimensions > capabilities.MaxDimensions:
+		return errors.Newf("OPENAI_EMBED_DIMENSIONS is malformed: must be at most %v for embed model %v",
+			capabilities.MaxDimensions, opts.DefaultEmbedModel)
+	}
+	return nil
+}
+
+// getEnvInt returns nil if the variable is unset, and an error if it's not an integer of at least minimum.
+func getEnvInt(name string, minimum int) (*int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil || i < minimum {
+		return nil, errors.Newf("%v is malformed: must be an integer of at least %v", name, minimum)
+	}
+	return &i, nil
+}
@@ -0,0 +1,130 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	t.Run("creates a client with default models from the environment", func(t *testing.T) {
+		var model string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "Bearer env-key", r.Header.Get("Authorization"))
+			is.Equal(t, "org-env", r.Header.Get("OpenAI-Organization"))
+
+			var body struct {
+				Model string `json:"model"`
+			}
+			is.NotError(t, json.NewDecoder(r.Body).Decode(&body))
+			model = body.Model
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_BASE_URL", s.URL)
+		t.Setenv("OPENAI_ORG_ID", "org-env")
+		t.Setenv("OPENAI_EMBED_MODEL", "text-embedding-3-large")
+		t.Setenv("OPENAI_EMBED_DIMENSIONS", "3")
+		t.Setenv("OPENAI_TIMEOUT", "10s")
+		t.Setenv("OPENAI_MAX_RETRIES", "0")
+
+		c, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.NotError(t, err)
+
+		e := c.NewEmbedder(openai.NewEmbedderOptions{})
+		_, err = e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, "text-embedding-3-large", model)
+	})
+
+	t.Run("doesn't require a key for other providers", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "")
+		t.Setenv("OPENAI_KEY", "")
+		t.Setenv("OPENAI_PROVIDER", "ollama")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.NotError(t, err)
+	})
+
+	t.Run("ignores the key with azure options", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_KEY", "env-key")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{Endpoint: "https://example.openai.azure.com", Key: "azure-key"},
+		})
+		is.NotError(t, err)
+	})
+
+	t.Run("errors on a base URL and provider with azure options", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "")
+		t.Setenv("OPENAI_KEY", "")
+		t.Setenv("OPENAI_BASE_URL", "https://example.com/v1/")
+		t.Setenv("OPENAI_PROVIDER", "ollama")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{
+			Azure: &openai.AzureOptions{Endpoint: "https://example.openai.azure.com", Key: "azure-key"},
+		})
+		is.True(t, err != nil, "should error")
+		is.True(t, strings.Contains(err.Error(), "OPENAI_BASE_URL is malformed: must not be set when using azure"), err.Error())
+		is.True(t, strings.Contains(err.Error(), "OPENAI_PROVIDER is malformed: must not be set when using azure"), err.Error())
+	})
+
+	t.Run("defaults embed dimensions to the max dimensions of a known model", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_EMBED_MODEL", "text-embedding-3-small")
+		t.Setenv("OPENAI_EMBED_DIMENSIONS", "")
+
+		c, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.NotError(t, err)
+		_ = c.NewEmbedder(openai.NewEmbedderOptions{})
+	})
+
+	t.Run("requires embed dimensions for an unknown model", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_EMBED_MODEL", "nomic-embed-text")
+		t.Setenv("OPENAI_EMBED_DIMENSIONS", "")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.True(t, err != nil, "should error")
+		is.True(t, strings.Contains(err.Error(), "OPENAI_EMBED_DIMENSIONS is missing"), err.Error())
+	})
+
+	t.Run("errors if embed dimensions are above the max dimensions of the model", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_EMBED_MODEL", "text-embedding-3-small")
+		t.Setenv("OPENAI_EMBED_DIMENSIONS", "3072")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.True(t, err != nil, "should error")
+		is.True(t, strings.Contains(err.Error(), "must be at most 1536"), err.Error())
+	})
+
+	t.Run("lists every missing and malformed variable", func(t *testing.T) {
+		t.Setenv("OPENAI_API_KEY", "")
+		t.Setenv("OPENAI_KEY", "")
+		t.Setenv("OPENAI_BASE_URL", "not a url")
+		t.Setenv("OPENAI_PROVIDER", "")
+		t.Setenv("OPENAI_EMBED_DIMENSIONS", "-1")
+		t.Setenv("OPENAI_TIMEOUT", "soon")
+		t.Setenv("OPENAI_MAX_RETRIES", "many")
+		t.Setenv("OPENAI_TOKENS_PER_MINUTE", "lots")
+
+		_, err := openai.NewClientFromEnv(openai.NewClientOptions{})
+		is.True(t, err != nil, "should error")
+
+		for _, name := range []string{"OPENAI_API_KEY is missing", "OPENAI_BASE_URL", "OPENAI_EMBED_DIMENSIONS", "OPENAI_TIMEOUT",
+			"OPENAI_MAX_RETRIES", "OPENAI_TOKENS_PER_MINUTE"} {
+			is.True(t, strings.Contains(err.Error(), name), "should mention "+name)
+		}
+	})
+}
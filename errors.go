@@ -0,0 +1,237 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"maragu.dev/errors"
+)
+
+// APIError is an error response from the OpenAI API.
+// Errors that don't match one of the more specific types below are returned as an *APIError.
+// Use [errors.As] to get the details.
+type APIError struct {
+	// StatusCode of the HTTP response. It's zero for errors received in a stream.
+	StatusCode int
+	// RequestID from the x-request-id response header, useful when contacting support.
+	RequestID string
+	// Type of the error, such as "invalid_request_error".
+	Type string
+	// Code of the error, such as "context_length_exceeded".
+	Code string
+	// Param is the request parameter the error is about, if any.
+	Param string
+	// Message from the API.
+	Message string
+	// RetryAfter is how long the API asks to wait before retrying, if it said so.
+	RetryAfter time.Duration
+	// Err is the underlying error from the OpenAI SDK.
+	Err error
+}
+
+func (e *APIError) Error() string {
+	var details []string
+	if e.StatusCode != 0 {
+		details = append(details, fmt.Sprintf("status %v", e.StatusCode))
+	}
+	if e.Code != "" {
+		details = append(details, "code "+e.Code)
+	}
+	if e.RequestID != "" {
+		details = append(details, "request "+e.RequestID)
+	}
+	if len(details) == 0 {
+		return "openai API error: " + e.Message
+	}
+	return fmt.Sprintf("openai API error (%v): %v", strings.Join(details, ", "), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitError is returned when a rate limit or quota is exceeded, with status 429.
+type RateLimitError struct {
+	*APIError
+	// Model the limit is for, if given in the message.
+	Model string
+	// LimitType is the kind of limit, such as "tokens per min (TPM)", if given in the message.
+	LimitType string
+	// Limit, Used, and Requested are the limit values, if given in the message.
+	Limit, Used, Requested int
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.APIError
+}
+
+// AuthenticationError is returned when the API key is missing or invalid, with status 401.
+type AuthenticationError struct {
+	*APIError
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.APIError
+}
+
+// ContextLengthExceededError is returned when the input is larger than the model's context window.
+type ContextLengthExceededError struct {
+	*APIError
+	// MaxTokens is the model's context length, if given in the message.
+	MaxTokens int
+	// RequestedTokens is the size of the input, if given in the message.
+	RequestedTokens int
+}
+
+func (e *ContextLengthExceededError) Unwrap() error {
+	return e.APIError
+}
+
+// ContentFilterError is returned when the input is rejected by a content filter or policy.
+type ContentFilterError struct {
+	*APIError
+}
+
+func (e *ContentFilterError) Unwrap() error {
+	return e.APIError
+}
+
+// ServerError is returned when the API fails on its side, with status 5xx or a server error in a stream.
+type ServerError struct {
+	*APIError
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.APIError
+}
+
+var (
+	rateLimitModelMatcher    = regexp.MustCompile(`(?i)rate limit reached for (\S+)`)
+	rateLimitTypeMatcher     = regexp.MustCompile(`(?i) on ([^:]+):`)
+	rateLimitValuesMatcher   = regexp.MustCompile(`(?i)limit (\d+), used (\d+), requested (\d+)`)
+	retryAfterMatcher        = regexp.MustCompile(`(?i)try again in ([\d.]+(?:ms|s|m|h)(?:[\d.]+(?:ms|s))*)`)
+	maxContextLengthMatcher  = regexp.MustCompile(`(?i)maximum context length is (\d+) tokens`)
+	requestedTokensMatcher   = regexp.MustCompile(`(?i)(?:resulted in|requested) (\d+) tokens`)
+	streamErrorMessagePrefix = "received error while streaming: "
+)
+
+// toAPIError converts errors from the OpenAI SDK to the typed errors above.
+// Other errors, and errors that have already been converted, are returned unchanged.
+func toAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	var sdkErr *openai.Error
+	switch {
+	case errors.As(err, &sdkErr):
+		apiErr = &APIError{
+			StatusCode: sdkErr.StatusCode,
+			Type:       sdkErr.Type,
+			Code:       sdkErr.Code,
+			Param:      sdkErr.Param,
+			Message:    sdkErr.Message,
+			Err:        err,
+		}
+		if sdkErr.Response != nil {
+			apiErr.RequestID = requestIDOf(sdkErr.Response)
+			if d, ok := parseRetryAfter(sdkErr.Response); ok {
+				apiErr.RetryAfter = d
+			}
+		}
+
+	case strings.HasPrefix(err.Error(), streamErrorMessagePrefix):
+		// Error events in streams are not returned as SDK errors, so parse the error object ourselves
+		var body struct {
+			Code    any    `json:"code"`
+			Message string `json:"message"`
+			Param   string `json:"param"`
+			Type    string `json:"type"`
+		}
+		if json.Unmarshal([]byte(strings.TrimPrefix(err.Error(), streamErrorMessagePrefix)), &body) != nil {
+			return err
+		}
+		apiErr = &APIError{
+			Type:    body.Type,
+			Param:   body.Param,
+			Message: body.Message,
+			Err:     err,
+		}
+		if body.Code != nil {
+			apiErr.Code = fmt.Sprint(body.Code)
+		}
+
+	default:
+		return err
+	}
+
+	if apiErr.RetryAfter == 0 {
+		if m := retryAfterMatcher.FindStringSubmatch(apiErr.Message); m != nil {
+			if d, err := time.ParseDuration(m[1]); err == nil {
+				apiErr.RetryAfter = d
+			}
+		}
+	}
+
+	switch {
+	case apiErr.Code == "context_length_exceeded":
+		e := &ContextLengthExceededError{APIError: apiErr}
+		e.MaxTokens = matchInt(maxContextLengthMatcher, apiErr.Message, 1)
+		e.RequestedTokens = matchInt(requestedTokensMatcher, apiErr.Message, 1)
+		return e
+
+	case apiErr.Code == "content_filter" || apiErr.Code == "content_policy_violation":
+		return &ContentFilterError{APIError: apiErr}
+
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.Code == "invalid_api_key":
+		return &AuthenticationError{APIError: apiErr}
+
+	case apiErr.StatusCode == http.StatusTooManyRequests || apiErr.Code == "rate_limit_exceeded":
+		e := &RateLimitError{APIError: apiErr}
+		if m := rateLimitModelMatcher.FindStringSubmatch(apiErr.Message); m != nil {
+			e.Model = m[1]
+		}
+		if m := rateLimitTypeMatcher.FindStringSubmatch(apiErr.Message); m != nil {
+			e.LimitType = m[1]
+		}
+		e.Limit = matchInt(rateLimitValuesMatcher, apiErr.Message, 1)
+		e.Used = matchInt(rateLimitValuesMatcher, apiErr.Message, 2)
+		e.Requested = matchInt(rateLimitValuesMatcher, apiErr.Message, 3)
+		return e
+
+	case apiErr.StatusCode >= http.StatusInternalServerError || apiErr.Type == "server_error":
+		return &ServerError{APIError: apiErr}
+
+	default:
+		return apiErr
+	}
+}
+
+// matchInt returns the integer in the given submatch group, or zero if there's no match.
+func matchInt(r *regexp.Regexp, s string, group int) int {
+	m := r.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.Atoi(m[group])
+	return v
+}
+
+// requestIDOf the response, from the x-request-id header. It's empty if there is no response.
+func requestIDOf(res *http.Response) string {
+	if res == nil {
+		return ""
+	}
+	return res.Header.Get("x-request-id")
+}
@@ -0,0 +1,140 @@
+package openai_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestAPIErrors(t *testing.T) {
+	t.Run("returns a rate limit error with parsed limit values", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusTooManyRequests, map[string]string{"x-request-id": "req_123", "retry-after-ms": "1500"},
+			`{"error":{"message":"Rate limit reached for text-embedding-3-small in organization org-abc on tokens per min (TPM): Limit 1000000, Used 999990, Requested 20. Please try again in 1ms.","type":"tokens","param":null,"code":"rate_limit_exceeded"}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var rateLimitErr *openai.RateLimitError
+		is.True(t, errors.As(err, &rateLimitErr), "should be a rate limit error")
+		is.Equal(t, http.StatusTooManyRequests, rateLimitErr.StatusCode)
+		is.Equal(t, "req_123", rateLimitErr.RequestID)
+		is.Equal(t, 1500*time.Millisecond, rateLimitErr.RetryAfter)
+		is.Equal(t, "text-embedding-3-small", rateLimitErr.Model)
+		is.Equal(t, "tokens per min (TPM)", rateLimitErr.LimitType)
+		is.Equal(t, 1000000, rateLimitErr.Limit)
+		is.Equal(t, 999990, rateLimitErr.Used)
+		is.Equal(t, 20, rateLimitErr.Requested)
+
+		var apiErr *openai.APIError
+		is.True(t, errors.As(err, &apiErr), "should also be an API error")
+	})
+
+	t.Run("returns an authentication error", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusUnauthorized, nil,
+			`{"error":{"message":"Incorrect API key provided: test.","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var authErr *openai.AuthenticationError
+		is.True(t, errors.As(err, &authErr), "should be an authentication error")
+		is.Equal(t, "invalid_api_key", authErr.Code)
+	})
+
+	t.Run("returns a context length exceeded error with parsed token counts", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusBadRequest, nil,
+			`{"error":{"message":"This model's maximum context length is 8192 tokens, however you requested 9000 tokens (9000 in your prompt; 0 for the completion). Please reduce your prompt; or completion length.","type":"invalid_request_error","param":null,"code":"context_length_exceeded"}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var contextErr *openai.ContextLengthExceededError
+		is.True(t, errors.As(err, &contextErr), "should be a context length exceeded error")
+		is.Equal(t, 8192, contextErr.MaxTokens)
+		is.Equal(t, 9000, contextErr.RequestedTokens)
+	})
+
+	t.Run("returns a content filter error", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusBadRequest, nil,
+			`{"error":{"message":"The response was filtered due to the prompt triggering content management policy.","type":null,"param":"prompt","code":"content_filter"}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var contentFilterErr *openai.ContentFilterError
+		is.True(t, errors.As(err, &contentFilterErr), "should be a content filter error")
+		is.Equal(t, "prompt", contentFilterErr.Param)
+	})
+
+	t.Run("returns a server error", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusInternalServerError, nil,
+			`{"error":{"message":"The server had an error while processing your request.","type":"server_error","param":null,"code":null}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var serverErr *openai.ServerError
+		is.True(t, errors.As(err, &serverErr), "should be a server error")
+		is.Equal(t, http.StatusInternalServerError, serverErr.StatusCode)
+	})
+
+	t.Run("returns an API error for other errors", func(t *testing.T) {
+		e := newErrorEmbedder(t, http.StatusBadRequest, nil,
+			`{"error":{"message":"Invalid model.","type":"invalid_request_error","param":"model","code":"model_not_found"}}`)
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+
+		var apiErr *openai.APIError
+		is.True(t, errors.As(err, &apiErr), "should be an API error")
+		is.Equal(t, "model_not_found", apiErr.Code)
+
+		var serverErr *openai.ServerError
+		is.True(t, !errors.As(err, &serverErr), "should not be a server error")
+	})
+
+	t.Run("returns typed errors from chat completion streams", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error","code":null}}` + "\n\n"))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", Retry: openai.RetryOptions{MaxRetries: -1}})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		for _, err = range res.Parts() {
+			if err != nil {
+				break
+			}
+		}
+
+		var serverErr *openai.ServerError
+		is.True(t, errors.As(err, &serverErr), "should be a server error")
+		is.Equal(t, "server_error", serverErr.Type)
+	})
+}
+
+func newErrorEmbedder(t *testing.T, status int, headers map[string]string, body string) *openai.Embedder {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", Retry: openai.RetryOptions{MaxRetries: -1}})
+	return c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+}
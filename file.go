@@ -0,0 +1,172 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"maragu.dev/errors"
+)
+
+type FilePurpose string
+
+const (
+	FilePurposeAssistants = FilePurpose(openai.FilePurposeAssistants)
+	FilePurposeBatch      = FilePurpose(openai.FilePurposeBatch)
+	FilePurposeEvals      = FilePurpose(openai.FilePurposeEvals)
+	FilePurposeFineTune   = FilePurpose(openai.FilePurposeFineTune)
+	FilePurposeUserData   = FilePurpose(openai.FilePurposeUserData)
+	FilePurposeVision     = FilePurpose(openai.FilePurposeVision)
+
+	// FilePurposeBatchOutput is set by the API on batch output and error files, it can't be used for uploads.
+	FilePurposeBatchOutput = FilePurpose(openai.FileObjectPurposeBatchOutput)
+)
+
+// File is an uploaded file.
+type File struct {
+	ID        string
+	Name      string
+	Purpose   FilePurpose
+	Size      int64
+	CreatedAt time.Time
+	// ExpiresAt is zero if the file doesn't expire.
+	ExpiresAt time.Time
+}
+
+type UploadFileOptions struct {
+	// Name of the file, including extension, which the API uses to detect the file type.
+	Name    string
+	Purpose FilePurpose
+	// ContentType of the file. Defaults to application/octet-stream.
+	ContentType string
+}
+
+// UploadFile streams the content of r to the Files API, without reading it all into memory first.
+// Because the content is streamed, the upload is not retried on failure.
+func (c *Client) UploadFile(ctx context.Context, r io.Reader, opts UploadFileOptions) (File, error) {
+	if opts.Name == "" {
+		return File{}, errors.New("file name must not be empty")
+	}
+	if opts.Purpose == "" {
+		return File{}, errors.New("file purpose must not be empty")
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/octet-stream"
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		err := func() error {
+			if err := mw.WriteField("purpose", string(opts.Purpose)); err != nil {
+				return err
+			}
+
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%v"`, quoteEscaper.Replace(opts.Name)))
+			h.Set("Content-Type", opts.ContentType)
+			part, err := mw.CreatePart(h)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, r); err != nil {
+				return err
+			}
+			return mw.Close()
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	var res openai.FileObject
+	if err := c.Client.Post(ctx, "files", pr, &res, option.WithHeader("Content-Type", mw.FormDataContentType())); err != nil {
+		// Make sure the writing goroutine is not blocked on the pipe
+		_ = pr.CloseWithError(err)
+		return File{}, errors.Wrap(toAPIError(err), "error uploading file")
+	}
+
+	return toFile(res), nil
+}
+
+// GetFile metadata by ID.
+func (c *Client) GetFile(ctx context.Context, id string) (File, error) {
+	res, err := c.Client.Files.Get(ctx, id)
+	if err != nil {
+		return File{}, errors.Wrap(toAPIError(err), "error getting file")
+	}
+	return toFile(*res), nil
+}
+
+type ListFilesOptions struct {
+	// Purpose to filter by. All files are listed if empty.
+	Purpose FilePurpose
+	// PageSize is how many files to fetch per request. Defaults to the API default.
+	PageSize int
+}
+
+// ListFiles iterates over all files, newest first, fetching pages as needed.
+// Iteration stops after the first error.
+func (c *Client) ListFiles(ctx context.Context, opts ListFilesOptions) iter.Seq2[File, error] {
+	return func(yield func(File, error) bool) {
+		params := openai.FileListParams{
+			Order: openai.FileListParamsOrderDesc,
+		}
+		if opts.Purpose != "" {
+			params.Purpose = openai.String(string(opts.Purpose))
+		}
+		if opts.PageSize > 0 {
+			params.Limit = openai.Int(int64(opts.PageSize))
+		}
+
+		pager := c.Client.Files.ListAutoPaging(ctx, params)
+		for pager.Next() {
+			if !yield(toFile(pager.Current()), nil) {
+				return
+			}
+		}
+		if err := pager.Err(); err != nil {
+			yield(File{}, errors.Wrap(toAPIError(err), "error listing files"))
+		}
+	}
+}
+
+// DownloadFile content by ID. The caller must close the returned reader.
+func (c *Client) DownloadFile(ctx context.Context, id string) (io.ReadCloser, error) {
+	res, err := c.Client.Files.Content(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(toAPIError(err), "error downloading file")
+	}
+	return res.Body, nil
+}
+
+// DeleteFile by ID.
+func (c *Client) DeleteFile(ctx context.Context, id string) error {
+	if _, err := c.Client.Files.Delete(ctx, id); err != nil {
+		return errors.Wrap(toAPIError(err), "error deleting file")
+	}
+	return nil
+}
+
+// quoteEscaper escapes quoted header values the same way as [multipart.Writer.CreateFormFile].
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func toFile(f openai.FileObject) File {
+	file := File{
+		ID:        f.ID,
+		Name:      f.Filename,
+		Purpose:   FilePurpose(f.Purpose),
+		Size:      f.Bytes,
+		CreatedAt: time.Unix(f.CreatedAt, 0).UTC(),
+	}
+	if f.ExpiresAt > 0 {
+		file.ExpiresAt = time.Unix(f.ExpiresAt, 0).UTC()
+	}
+	return file
+}
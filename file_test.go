@@ -0,0 +1,91 @@
+package openai_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestClient_Files(t *testing.T) {
+	t.Run("can upload, get, list, download, and delete a file", func(t *testing.T) {
+		c := newClient(t)
+
+		file, err := c.UploadFile(t.Context(), strings.NewReader("Hi!\n"), openai.UploadFileOptions{
+			Name:        "hi.txt",
+			Purpose:     openai.FilePurposeUserData,
+			ContentType: "text/plain",
+		})
+		is.NotError(t, err)
+		is.True(t, file.ID != "", "should have an ID")
+		is.Equal(t, "hi.txt", file.Name)
+		is.Equal(t, openai.FilePurposeUserData, file.Purpose)
+		is.Equal(t, int64(4), file.Size)
+
+		t.Cleanup(func() {
+			_ = c.DeleteFile(t.Context(), file.ID)
+		})
+
+		got, err := c.GetFile(t.Context(), file.ID)
+		is.NotError(t, err)
+		is.Equal(t, file.ID, got.ID)
+
+		var found bool
+		for f, err := range c.ListFiles(t.Context(), openai.ListFilesOptions{Purpose: openai.FilePurposeUserData}) {
+			is.NotError(t, err)
+			if f.ID == file.ID {
+				found = true
+				break
+			}
+		}
+		is.True(t, found, "should find uploaded file in list")
+
+		r, err := c.DownloadFile(t.Context(), file.ID)
+		is.NotError(t, err)
+		content, err := io.ReadAll(r)
+		is.NotError(t, err)
+		is.NotError(t, r.Close())
+		is.Equal(t, "Hi!\n", string(content))
+
+		err = c.DeleteFile(t.Context(), file.ID)
+		is.NotError(t, err)
+	})
+
+	t.Run("streams the upload as multipart form data", func(t *testing.T) {
+		var purpose, name, contentType, content string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/files", r.URL.Path)
+
+			purpose = r.FormValue("purpose")
+			f, h, err := r.FormFile("file")
+			is.NotError(t, err)
+			name = h.Filename
+			contentType = h.Header.Get("Content-Type")
+			b, err := io.ReadAll(f)
+			is.NotError(t, err)
+			content = string(b)
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"file-1","object":"file","bytes":4,"created_at":1700000000,"filename":"hi.txt","purpose":"batch","status":"processed"}`))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+
+		file, err := c.UploadFile(t.Context(), strings.NewReader("Hi!\n"), openai.UploadFileOptions{
+			Name:    "hi.txt",
+			Purpose: openai.FilePurposeBatch,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "file-1", file.ID)
+		is.Equal(t, "batch", purpose)
+		is.Equal(t, "hi.txt", name)
+		is.Equal(t, "application/octet-stream", contentType)
+		is.Equal(t, "Hi!\n", content)
+	})
+}
@@ -0,0 +1,232 @@
+// Package cassette records HTTP interactions to files and replays them, so tests can run offline.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"maragu.dev/errors"
+)
+
+type Mode int
+
+const (
+	// ModeReplay responds with recorded interactions and never calls the real API.
+	ModeReplay Mode = iota
+	// ModeRecord calls the real API and records the interactions.
+	ModeRecord
+)
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string `json:"method"`
+	// URL is the path and query of the request.
+	URL string `json:"url"`
+	// Body is normalized, see [normalizeBody].
+	Body string `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	// Body is the full response body, including server-sent events for streams.
+	Body string `json:"body"`
+}
+
+// recordedHeaders are the only response headers recorded, so nothing identifying the account is stored.
+var recordedHeaders = []string{"Content-Type", "X-Request-Id"}
+
+// secretMatcher matches API keys and organization and project IDs.
+var secretMatcher = regexp.MustCompile(`\b(sk-[A-Za-z0-9_-]{8,}|org-[A-Za-z0-9]{8,}|proj_[A-Za-z0-9]{8,})`)
+
+// Transport is an [http.RoundTripper] that records or replays interactions in a cassette file.
+type Transport struct {
+	interactions []Interaction
+	lock         sync.Mutex
+	mode         Mode
+	next         http.RoundTripper
+	path         string
+	used         []bool
+}
+
+// New [Transport] for the cassette file at path.
+// In replay mode, the file is read immediately. In record mode, next is used for the real requests,
+// and the file is written by [Transport.Save].
+func New(path string, mode Mode, next http.RoundTripper) (*Transport, error) {
+	t := &Transport{
+		mode: mode,
+		next: next,
+		path: path,
+	}
+
+	if mode == ModeRecord {
+		return t, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading cassette")
+	}
+	if err := json.Unmarshal(b, &t.interactions); err != nil {
+		return nil, errors.Wrap(err, "error decoding cassette")
+	}
+	t.used = make([]bool, len(t.interactions))
+	return t, nil
+}
+
+// RoundTrip satisfies [http.RoundTripper].
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recordedReq, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.mode == ModeRecord {
+		return t.record(req, recordedReq)
+	}
+	return t.replay(req, recordedReq)
+}
+
+// replay the first unused interaction that matches the request, so identical requests are replayed in order.
+func (t *Transport) replay(req *http.Request, recordedReq Request) (*http.Response, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i, interaction := range t.interactions {
+		if t.used[i] || interaction.Request != recordedReq {
+			continue
+		}
+		t.used[i] = true
+
+		res := &http.Response{
+			StatusCode:    interaction.Response.StatusCode,
+			Status:        http.StatusText(interaction.Response.StatusCode),
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{},
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}
+		for k, v := range interaction.Response.Headers {
+			res.Header.Set(k, v)
+		}
+		return res, nil
+	}
+
+	return nil, errors.Newf("no recorded interaction in %v for %v %v", t.path, recordedReq.Method, recordedReq.URL)
+}
+
+func (t *Transport) record(req *http.Request, recordedReq Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading response body")
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	recordedRes := Response{
+		StatusCode: res.StatusCode,
+		Headers:    map[string]string{},
+		Body:       scrub(string(body)),
+	}
+	for _, k := range recordedHeaders {
+		if v := res.Header.Get(k); v != "" {
+			recordedRes.Headers[k] = scrub(v)
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.interactions = append(t.interactions, Interaction{Request: recordedReq, Response: recordedRes})
+	return res, nil
+}
+
+// Save the recorded interactions to the cassette file. It does nothing in replay mode.
+func (t *Transport) Save() error {
+	if t.mode != ModeRecord {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	b, err := json.MarshalIndent(t.interactions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error encoding cassette")
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return errors.Wrap(err, "error creating cassette directory")
+	}
+	if err := os.WriteFile(t.path, append(b, '\n'), 0o644); err != nil {
+		return errors.Wrap(err, "error writing cassette")
+	}
+	return nil
+}
+
+// newRequest for matching and recording, with a normalized body. It restores the request body after reading it.
+func newRequest(req *http.Request) (Request, error) {
+	r := Request{
+		Method: req.Method,
+		URL:    req.URL.RequestURI(),
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return r, errors.Wrap(err, "error reading request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.Body = normalizeBody(req.Header.Get("Content-Type"), body)
+	return r, nil
+}
+
+// normalizeBody so equivalent requests match:
+// JSON is re-encoded with sorted keys, and random multipart boundaries are replaced with a fixed one.
+// Secrets are scrubbed.
+func normalizeBody(contentType string, body []byte) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/json":
+		var v any
+		if err := json.Unmarshal(body, &v); err == nil {
+			if b, err := json.Marshal(v); err == nil {
+				body = b
+			}
+		}
+
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("BOUNDARY"))
+	}
+
+	return scrub(string(body))
+}
+
+func scrub(s string) string {
+	return secretMatcher.ReplaceAllString(s, "REDACTED")
+}
@@ -0,0 +1,87 @@
+package cassette_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/gai-openai/internal/cassette"
+)
+
+func TestTransport(t *testing.T) {
+	t.Run("records interactions with secrets scrubbed and replays them by normalized request body", func(t *testing.T) {
+		var calls int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Openai-Organization", "org-abcdefghijklmnop")
+			w.Header().Set("X-Request-Id", "req_"+strings.Repeat("1", calls))
+			_, _ = w.Write([]byte("data: {\"call\":" + strings.Repeat("1", calls) + "}\n\ndata: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		path := filepath.Join(t.TempDir(), "cassette.json")
+
+		recorder, err := cassette.New(path, cassette.ModeRecord, http.DefaultTransport)
+		is.NotError(t, err)
+		client := &http.Client{Transport: recorder}
+
+		for range 2 {
+			body := doRequest(t, client, s.URL+"/v1/chat/completions", `{"model":"gpt-4o-mini","key":"sk-abcdefghijklmnop"}`)
+			is.True(t, strings.HasPrefix(body, "data: "), body)
+		}
+		is.NotError(t, recorder.Save())
+
+		b, err := os.ReadFile(path)
+		is.NotError(t, err)
+		is.True(t, !strings.Contains(string(b), "sk-abcdefghijklmnop"), "should scrub keys")
+		is.True(t, !strings.Contains(string(b), "org-abcdefghijklmnop"), "should not record other headers")
+
+		replayer, err := cassette.New(path, cassette.ModeReplay, nil)
+		is.NotError(t, err)
+		client = &http.Client{Transport: replayer}
+
+		// Keys in a different order match the same interactions, in the order they were recorded
+		body := doRequest(t, client, "http://example.com/v1/chat/completions", `{"key":"sk-abcdefghijklmnop", "model":"gpt-4o-mini"}`)
+		is.Equal(t, "data: {\"call\":1}\n\ndata: [DONE]\n\n", body)
+		body = doRequest(t, client, "http://example.com/v1/chat/completions", `{"key":"sk-abcdefghijklmnop", "model":"gpt-4o-mini"}`)
+		is.Equal(t, "data: {\"call\":11}\n\ndata: [DONE]\n\n", body)
+		is.Equal(t, 2, calls)
+
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o-mini"}`))
+		is.NotError(t, err)
+		_, err = client.Do(req)
+		is.True(t, err != nil, "should error on unrecorded request")
+		is.True(t, strings.Contains(err.Error(), "no recorded interaction"), err.Error())
+	})
+
+	t.Run("errors in replay mode if the cassette doesn't exist", func(t *testing.T) {
+		_, err := cassette.New(filepath.Join(t.TempDir(), "missing.json"), cassette.ModeReplay, nil)
+		is.True(t, err != nil, "should error")
+	})
+}
+
+func doRequest(t *testing.T, client *http.Client, url, body string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	is.NotError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer sk-abcdefghijklmnop")
+
+	res, err := client.Do(req)
+	is.NotError(t, err)
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	b, err := io.ReadAll(res.Body)
+	is.NotError(t, err)
+	return string(b)
+}
@@ -0,0 +1,156 @@
+package openai
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"maragu.dev/errors"
+)
+
+// metrics for requests, shared by all chat completers and embedders created from the same [Client].
+// All instruments have the attributes gen_ai.operation.name and gen_ai.request.model, like the spans.
+type metrics struct {
+	completionTokens metric.Int64Counter
+	errors           metric.Int64Counter
+	promptTokens     metric.Int64Counter
+	requestDuration  metric.Float64Histogram
+	requests         metric.Int64Counter
+	timeToFirstToken metric.Float64Histogram
+	tokensPerSecond  metric.Float64Histogram
+}
+
+func newMetrics(mp metric.MeterProvider) *metrics {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter("maragu.dev/gai-openai")
+
+	var m metrics
+	var err error
+	if m.requests, err = meter.Int64Counter("openai.requests",
+		metric.WithDescription("Number of requests."),
+		metric.WithUnit("{request}")); err != nil {
+		panic(err)
+	}
+	if m.errors, err = meter.Int64Counter("openai.errors",
+		metric.WithDescription("Number of failed requests, by error.type."),
+		metric.WithUnit("{error}")); err != nil {
+		panic(err)
+	}
+	if m.requestDuration, err = meter.Float64Histogram("openai.request.duration",
+		metric.WithDescription("Duration of requests, including reading streamed responses."),
+		metric.WithUnit("s")); err != nil {
+		panic(err)
+	}
+	if m.timeToFirstToken, err = meter.Float64Histogram("openai.time_to_first_token",
+		metric.WithDescription("Time from the start of a request to the first streamed text or tool call."),
+		metric.WithUnit("s")); err != nil {
+		panic(err)
+	}
+	if m.tokensPerSecond, err = meter.Float64Histogram("openai.tokens_per_second",
+		metric.WithDescription("Completion tokens per second, after the first token."),
+		metric.WithUnit("{token}/s")); err != nil {
+		panic(err)
+	}
+	if m.promptTokens, err = meter.Int64Counter("openai.tokens.prompt",
+		metric.WithDescription("Number of prompt tokens used."),
+		metric.WithUnit("{token}")); err != nil {
+		panic(err)
+	}
+	if m.completionTokens, err = meter.Int64Counter("openai.tokens.completion",
+		metric.WithDescription("Number of completion tokens used."),
+		metric.WithUnit("{token}")); err != nil {
+		panic(err)
+	}
+	return &m
+}
+
+// requestMetrics for a single request, from [metrics.start] to [requestMetrics.end].
+type requestMetrics struct {
+	attrs      metric.MeasurementOption
+	firstToken time.Time
+	m          *metrics
+	start      time.Time
+}
+
+func (m *metrics) start(operation, model string) *requestMetrics {
+	return &requestMetrics{
+		attrs: metric.WithAttributes(
+			genAIOperationName.String(operation),
+			genAIRequestModel.String(model),
+		),
+		m:     m,
+		start: time.Now(),
+	}
+}
+
+// token records the time to the first token, if it's the first call.
+func (r *requestMetrics) token(ctx context.Context) {
+	if !r.firstToken.IsZero() {
+		return
+	}
+	r.firstToken = time.Now()
+	r.m.timeToFirstToken.Record(ctx, r.firstToken.Sub(r.start).Seconds(), r.attrs)
+}
+
+// end the request, recording its duration, token usage, and error, if any.
+func (r *requestMetrics) end(ctx context.Context, promptTokens, completionTokens int, err error) {
+	now := time.Now()
+
+	r.m.requests.Add(ctx, 1, r.attrs)
+	r.m.requestDuration.Record(ctx, now.Sub(r.start).Seconds(), r.attrs)
+
+	if promptTokens > 0 {
+		r.m.promptTokens.Add(ctx, int64(promptTokens), r.attrs)
+	}
+	if completionTokens > 0 {
+		r.m.completionTokens.Add(ctx, int64(completionTokens), r.attrs)
+		if !r.firstToken.IsZero() && now.After(r.firstToken) {
+			r.m.tokensPerSecond.Record(ctx, float64(completionTokens)/now.Sub(r.firstToken).Seconds(), r.attrs)
+		}
+	}
+
+	if err != nil {
+		r.m.errors.Add(ctx, 1, r.attrs, metric.WithAttributes(attribute.String("error.type", errorType(err))))
+	}
+}
+
+// errorType for the error.type attribute, based on the typed errors.
+func errorType(err error) string {
+	var rateLimitErr *RateLimitError
+	var authErr *AuthenticationError
+	var contextErr *ContextLengthExceededError
+	var contentFilterErr *ContentFilterError
+	var serverErr *ServerError
+	var apiErr *APIError
+
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return "client_rate_limit"
+	case errors.Is(err, ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, ErrUnsupported):
+		return "unsupported"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &rateLimitErr):
+		return "rate_limit"
+	case errors.As(err, &authErr):
+		return "authentication"
+	case errors.As(err, &contextErr):
+		return "context_length_exceeded"
+	case errors.As(err, &contentFilterErr):
+		return "content_filter"
+	case errors.As(err, &serverErr):
+		return "server"
+	case errors.As(err, &apiErr):
+		return "api"
+	default:
+		return "other"
+	}
+}
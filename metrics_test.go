@@ -0,0 +1,160 @@
+package openai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_Metrics(t *testing.T) {
+	t.Run("records requests, latency, and tokens for chat completions", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"}}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		reader := sdkmetric.NewManualReader()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:       s.URL,
+			Key:           "test",
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		metrics := collectMetrics(t, reader)
+
+		is.Equal(t, int64(1), sumOf(t, metrics["openai.requests"]))
+		is.Equal(t, int64(10), sumOf(t, metrics["openai.tokens.prompt"]))
+		is.Equal(t, int64(2), sumOf(t, metrics["openai.tokens.completion"]))
+		is.Equal(t, uint64(1), countOf(t, metrics["openai.request.duration"]))
+		is.Equal(t, uint64(1), countOf(t, metrics["openai.time_to_first_token"]))
+
+		attrs := metrics["openai.requests"].Data.(metricdata.Sum[int64]).DataPoints[0].Attributes
+		operation, _ := attrs.Value("gen_ai.operation.name")
+		is.Equal(t, "chat", operation.AsString())
+		model, _ := attrs.Value("gen_ai.request.model")
+		is.Equal(t, "gpt-4o-mini", model.AsString())
+	})
+
+	t.Run("records errors by type for embeddings", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"Rate limit reached.","type":"requests","param":null,"code":"rate_limit_exceeded"}}`))
+		}))
+		defer s.Close()
+
+		reader := sdkmetric.NewManualReader()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:       s.URL,
+			Key:           "test",
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+			Retry:         openai.RetryOptions{MaxRetries: -1},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, err != nil, "should error")
+
+		metrics := collectMetrics(t, reader)
+
+		is.Equal(t, int64(1), sumOf(t, metrics["openai.requests"]))
+		errors := metrics["openai.errors"].Data.(metricdata.Sum[int64]).DataPoints
+		is.Equal(t, 1, len(errors))
+		is.Equal(t, int64(1), errors[0].Value)
+		errorType, _ := errors[0].Attributes.Value(attribute.Key("error.type"))
+		is.Equal(t, "rate_limit", errorType.AsString())
+	})
+}
+
+func TestChatCompleter_ChatComplete_Unsupported(t *testing.T) {
+	t.Run("records unsupported requests in traces and metrics", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			Key:            "test",
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		_, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{
+				{Role: gai.MessageRoleUser, Parts: []gai.MessagePart{{Type: gai.MessagePartTypeData, MIMEType: "image/png"}}},
+			},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+
+		spans := recorder.Ended()
+		is.Equal(t, 1, len(spans))
+		is.Equal(t, codes.Error, spans[0].Status().Code)
+		is.Equal(t, "unsupported", attributesOf(spans[0])["error.type"].AsString())
+
+		metrics := collectMetrics(t, reader)
+		errorPoints := metrics["openai.errors"].Data.(metricdata.Sum[int64]).DataPoints
+		is.Equal(t, 1, len(errorPoints))
+		errorType, _ := errorPoints[0].Attributes.Value(attribute.Key("error.type"))
+		is.Equal(t, "unsupported", errorType.AsString())
+	})
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Metrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	is.NotError(t, reader.Collect(context.Background(), &rm))
+
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func sumOf(t *testing.T, m metricdata.Metrics) int64 {
+	t.Helper()
+
+	var sum int64
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		sum += dp.Value
+	}
+	return sum
+}
+
+func countOf(t *testing.T, m metricdata.Metrics) uint64 {
+	t.Helper()
+
+	var count uint64
+	for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+		count += dp.Count
+	}
+	return count
+}
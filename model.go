@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+)
+
+// Model is a model available from the API.
+type Model struct {
+	ID        string
+	OwnedBy   string
+	CreatedAt time.Time
+}
+
+// ListModels available from the API.
+// Compatible APIs like llama.cpp, vLLM, and Ollama also implement this, but may not set all fields.
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	page, err := c.Client.Models.List(ctx)
+	if err != nil {
+		return nil, errors.Wrap(toAPIError(err), "error listing models")
+	}
+
+	var models []Model
+	for _, m := range page.Data {
+		model := Model{
+			ID:      m.ID,
+			OwnedBy: m.OwnedBy,
+		}
+		if m.Created > 0 {
+			model.CreatedAt = time.Unix(m.Created, 0).UTC()
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// ChatCompleteModelCapabilities describes what a [ChatCompleteModel] supports.
+type ChatCompleteModelCapabilities struct {
+	// ContextWindow in tokens, including output.
+	ContextWindow int
+	// MaxOutputTokens the model can generate in one response.
+	MaxOutputTokens  int
+	Audio            bool
+	Reasoning        bool
+	StructuredOutput bool
+	Tools            bool
+	Vision           bool
+}
+
+// EmbedModelCapabilities describes what an [EmbedModel] supports.
+type EmbedModelCapabilities struct {
+	// MaxDimensions of the embedding vector.
+	MaxDimensions int
+	// MaxInputTokens per input.
+	MaxInputTokens int
+}
+
+// ErrUnsupported is returned when a request uses a feature that the model doesn't support, according to its registered capabilities,
+// or that can't be converted to an API request, such as an unknown message part type.
+var ErrUnsupported = errors.New("unsupported by model")
+
+var capabilitiesLock sync.RWMutex
+
+var chatCompleteModelCapabilities = map[ChatCompleteModel]ChatCompleteModelCapabilities{
+	ChatCompleteModelGPT4o: {
+		ContextWindow:    128_000,
+		MaxOutputTokens:  16_384,
+		StructuredOutput: true,
+		Tools:            true,
+		Vision:           true,
+	},
+	ChatCompleteModelGPT4oMini: {
+		ContextWindow:    128_000,
+		MaxOutputTokens:  16_384,
+		StructuredOutput: true,
+		Tools:            true,
+		Vision:           true,
+	},
+}
+
+var embedModelCapabilities = map[EmbedModel]EmbedModelCapabilities{
+	EmbedModelTextEmbedding3Large: {
+		MaxDimensions:  3072,
+		MaxInputTokens: 8191,
+	},
+	EmbedModelTextEmbedding3Small: {
+		MaxDimensions:  1536,
+		MaxInputTokens: 8191,
+	},
+}
+
+// RegisterChatCompleteModel capabilities, for models this package doesn't know about, such as fine-tuned or self-hosted models.
+// Registering a known model overrides its built-in capabilities.
+func RegisterChatCompleteModel(model ChatCompleteModel, capabilities ChatCompleteModelCapabilities) {
+	capabilitiesLock.Lock()
+	defer capabilitiesLock.Unlock()
+	chatCompleteModelCapabilities[model] = capabilities
+}
+
+// RegisterEmbedModel capabilities, for models this package doesn't know about.
+// Registering a known model overrides its built-in capabilities.
+func RegisterEmbedModel(model EmbedModel, capabilities EmbedModelCapabilities) {
+	capabilitiesLock.Lock()
+	defer capabilitiesLock.Unlock()
+	embedModelCapabilities[model] = capabilities
+}
+
+// GetChatCompleteModelCapabilities returns the capabilities for the model, and false if the model is unknown.
+func GetChatCompleteModelCapabilities(model ChatCompleteModel) (ChatCompleteModelCapabilities, bool) {
+	capabilitiesLock.RLock()
+	defer capabilitiesLock.RUnlock()
+	capabilities, ok := chatCompleteModelCapabilities[model]
+	return capabilities, ok
+}
+
+// GetEmbedModelCapabilities returns the capabilities for the model, and false if the model is unknown.
+func GetEmbedModelCapabilities(model EmbedModel) (EmbedModelCapabilities, bool) {
+	capabilitiesLock.RLock()
+	defer capabilitiesLock.RUnlock()
+	capabilities, ok := embedModelCapabilities[model]
+	return capabilities, ok
+}
+
+// checkChatCompleteRequest against the model capabilities, if they are known.
+func checkChatCompleteRequest(model ChatCompleteModel, req gai.ChatCompleteRequest) error {
+	capabilities, ok := GetChatCompleteModelCapabilities(model)
+	if !ok {
+		return nil
+	}
+
+	if len(req.Tools) > 0 && !capabilities.Tools {
+		return errors.Newf("%w: model %v doesn't support tools", ErrUnsupported, model)
+	}
+
+	if req.ResponseSchema != nil && !capabilities.StructuredOutput {
+		return errors.Newf("%w: model %v doesn't support structured output", ErrUnsupported, model)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package openai_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/gai/tools"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestClient_ListModels(t *testing.T) {
+	t.Run("can list models", func(t *testing.T) {
+		c := newClient(t)
+
+		models, err := c.ListModels(t.Context())
+		is.NotError(t, err)
+
+		var found bool
+		for _, m := range models {
+			if m.ID == string(openai.ChatCompleteModelGPT4oMini) {
+				found = true
+				is.True(t, !m.CreatedAt.IsZero(), "should have a creation time")
+			}
+		}
+		is.True(t, found, "should find gpt-4o-mini")
+	})
+}
+
+func TestGetChatCompleteModelCapabilities(t *testing.T) {
+	t.Run("returns capabilities for known models", func(t *testing.T) {
+		capabilities, ok := openai.GetChatCompleteModelCapabilities(openai.ChatCompleteModelGPT4oMini)
+		is.True(t, ok)
+		is.True(t, capabilities.Tools)
+		is.Equal(t, 128_000, capabilities.ContextWindow)
+	})
+
+	t.Run("returns false for unknown models", func(t *testing.T) {
+		_, ok := openai.GetChatCompleteModelCapabilities("gemma3:1b")
+		is.True(t, !ok)
+	})
+
+	t.Run("rejects requests with unsupported features before calling the API", func(t *testing.T) {
+		model := openai.ChatCompleteModel("test-no-tools")
+		openai.RegisterChatCompleteModel(model, openai.ChatCompleteModelCapabilities{})
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: "http://localhost:0", Key: "test"})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: model})
+
+		root, err := os.OpenRoot("testdata")
+		is.NotError(t, err)
+
+		_, err = cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+			Tools:    []gai.Tool{tools.NewReadFile(root)},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+
+		_, err = cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages:       []gai.Message{gai.NewUserTextMessage("Hi!")},
+			ResponseSchema: &gai.Schema{Type: gai.SchemaTypeObject},
+		})
+		is.True(t, errors.Is(err, openai.ErrUnsupported), "should be unsupported")
+	})
+}
+
+func TestGetEmbedModelCapabilities(t *testing.T) {
+	t.Run("returns capabilities for known models", func(t *testing.T) {
+		capabilities, ok := openai.GetEmbedModelCapabilities(openai.EmbedModelTextEmbedding3Small)
+		is.True(t, ok)
+		is.Equal(t, 1536, capabilities.MaxDimensions)
+	})
+}
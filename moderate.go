@@ -0,0 +1,325 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+)
+
+type ModerateModel string
+
+const (
+	ModerateModelOmniModerationLatest = ModerateModel(openai.ModerationModelOmniModerationLatest)
+	ModerateModelTextModerationLatest = ModerateModel(openai.ModerationModelTextModerationLatest)
+)
+
+// ModerationCategory is a category the moderation model classifies content into.
+type ModerationCategory string
+
+const (
+	ModerationCategoryHarassment            = ModerationCategory("harassment")
+	ModerationCategoryHarassmentThreatening = ModerationCategory("harassment/threatening")
+	ModerationCategoryHate                  = ModerationCategory("hate")
+	ModerationCategoryHateThreatening       = ModerationCategory("hate/threatening")
+	ModerationCategoryIllicit               = ModerationCategory("illicit")
+	ModerationCategoryIllicitViolent        = ModerationCategory("illicit/violent")
+	ModerationCategorySelfHarm              = ModerationCategory("self-harm")
+	ModerationCategorySelfHarmInstructions  = ModerationCategory("self-harm/instructions")
+	ModerationCategorySelfHarmIntent        = ModerationCategory("self-harm/intent")
+	ModerationCategorySexual                = ModerationCategory("sexual")
+	ModerationCategorySexualMinors          = ModerationCategory("sexual/minors")
+	ModerationCategoryViolence              = ModerationCategory("violence")
+	ModerationCategoryViolenceGraphic       = ModerationCategory("violence/graphic")
+)
+
+type Moderator struct {
+	Client               openai.Client
+	legacySpanAttributes bool
+	log                  *slog.Logger
+	model                ModerateModel
+	system               string
+	tracer               trace.Tracer
+}
+
+type NewModeratorOptions struct {
+	Model ModerateModel
+}
+
+func (c *Client) NewModerator(opts NewModeratorOptions) *Moderator {
+	if opts.Model == "" {
+		opts.Model = ModerateModelOmniModerationLatest
+	}
+
+	return &Moderator{
+		Client:               c.Client,
+		legacySpanAttributes: c.legacySpanAttributes,
+		log:                  c.log,
+		model:                opts.Model,
+		system:               c.system,
+		tracer:               c.tracer,
+	}
+}
+
+// ModerateRequest is the input to [Moderator.Moderate].
+// Images are only supported by the omni moderation models.
+type ModerateRequest struct {
+	Text      string
+	ImageURLs []string
+}
+
+// ModerateResponse is the result of [Moderator.Moderate].
+type ModerateResponse struct {
+	// Flagged is true if any category is flagged.
+	Flagged    bool
+	Categories map[ModerationCategory]ModerationCategoryResult
+}
+
+// ModerationCategoryResult is the moderation result for a single [ModerationCategory].
+type ModerationCategoryResult struct {
+	Flagged bool
+	Score   float64
+	// InputTypes the score applies to, such as "text" and "image".
+	InputTypes []string
+}
+
+// FlaggedCategories returns the flagged categories, sorted by name.
+func (r ModerateResponse) FlaggedCategories() []ModerationCategory {
+	var categories []ModerationCategory
+	for category, result := range r.Categories {
+		if result.Flagged {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+	return categories
+}
+
+// Moderate text and/or images.
+func (m *Moderator) Moderate(ctx context.Context, req ModerateRequest) (ModerateResponse, error) {
+	attrs := append(genAIAttributes("moderation", m.system, string(m.model)),
+		attribute.Int("ai.input_length", len(req.Text)),
+		attribute.Int("ai.image_count", len(req.ImageURLs)),
+	)
+	if m.legacySpanAttributes {
+		attrs = append(attrs, attribute.String("ai.model", string(m.model)))
+	}
+
+	ctx, span := m.tracer.Start(ctx, "moderation "+string(m.model),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	var inputs []openai.ModerationMultiModalInputUnionParam
+	if req.Text != "" {
+		inputs = append(inputs, openai.ModerationMultiModalInputParamOfText(req.Text))
+	}
+	for _, url := range req.ImageURLs {
+		inputs = append(inputs, openai.ModerationMultiModalInputParamOfImageURL(openai.ModerationImageURLInputImageURLParam{URL: url}))
+	}
+	if len(inputs) == 0 {
+		return ModerateResponse{}, errors.New("no text or images to moderate")
+	}
+
+	res, err := m.Client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfModerationMultiModalArray: inputs},
+		Model: openai.ModerationModel(m.model),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "moderation request failed")
+		return ModerateResponse{}, errors.Wrap(toAPIError(err), "error moderating")
+	}
+	if len(res.Results) == 0 {
+		err := errors.New("no moderation results returned")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "no moderation results in response")
+		return ModerateResponse{}, err
+	}
+
+	r := res.Results[0]
+	c, s, t := r.Categories, r.CategoryScores, r.CategoryAppliedInputTypes
+	result := ModerateResponse{
+		Flagged: r.Flagged,
+		Categories: map[ModerationCategory]ModerationCategoryResult{
+			ModerationCategoryHarassment:            {c.Harassment, s.Harassment, t.Harassment},
+			ModerationCategoryHarassmentThreatening: {c.HarassmentThreatening, s.HarassmentThreatening, t.HarassmentThreatening},
+			ModerationCategoryHate:                  {c.Hate, s.Hate, t.Hate},
+			ModerationCategoryHateThreatening:       {c.HateThreatening, s.HateThreatening, t.HateThreatening},
+			ModerationCategoryIllicit:               {c.Illicit, s.Illicit, t.Illicit},
+			ModerationCategoryIllicitViolent:        {c.IllicitViolent, s.IllicitViolent, t.IllicitViolent},
+			ModerationCategorySelfHarm:              {c.SelfHarm, s.SelfHarm, t.SelfHarm},
+			ModerationCategorySelfHarmInstructions:  {c.SelfHarmInstructions, s.SelfHarmInstructions, t.SelfHarmInstructions},
+			ModerationCategorySelfHarmIntent:        {c.SelfHarmIntent, s.SelfHarmIntent, t.SelfHarmIntent},
+			ModerationCategorySexual:                {c.Sexual, s.Sexual, t.Sexual},
+			ModerationCategorySexualMinors:          {c.SexualMinors, s.SexualMinors, t.SexualMinors},
+			ModerationCategoryViolence:              {c.Violence, s.Violence, t.Violence},
+			ModerationCategoryViolenceGraphic:       {c.ViolenceGraphic, s.ViolenceGraphic, t.ViolenceGraphic},
+		},
+	}
+
+	var flagged []string
+	for _, category := range result.FlaggedCategories() {
+		flagged = append(flagged, string(category))
+	}
+	span.SetAttributes(
+		attribute.Bool("ai.flagged", result.Flagged),
+		attribute.StringSlice("ai.flagged_categories", flagged),
+	)
+
+	return result, nil
+}
+
+// ModerationFlaggedError is returned by [ModeratedChatCompleter] when input or output is flagged.
+type ModerationFlaggedError struct {
+	// Output is true if the model output was flagged, false if the input was.
+	Output   bool
+	Response ModerateResponse
+}
+
+func (e *ModerationFlaggedError) Error() string {
+	var categories []string
+	for _, category := range e.Response.FlaggedCategories() {
+		categories = append(categories, string(category))
+	}
+
+	kind := "input"
+	if e.Output {
+		kind = "output"
+	}
+	return fmt.Sprintf("%v flagged by moderation: %v", kind, strings.Join(categories, ", "))
+}
+
+// ModeratedChatCompleter wraps a [gai.ChatCompleter] and moderates its input and output.
+type ModeratedChatCompleter struct {
+	chatCompleter gai.ChatCompleter
+	moderator     *Moderator
+	skipInput     bool
+	skipOutput    bool
+}
+
+type NewModeratedChatCompleterOptions struct {
+	ChatCompleter gai.ChatCompleter
+	// SkipInput disables moderation of the latest user message before the request.
+	SkipInput bool
+	// SkipOutput disables moderation of the model output.
+	SkipOutput bool
+}
+
+// NewModeratedChatCompleter wraps any [gai.ChatCompleter], not just the ones from this package.
+func (m *Moderator) NewModeratedChatCompleter(opts NewModeratedChatCompleterOptions) *ModeratedChatCompleter {
+	if opts.ChatCompleter == nil {
+		panic("chat completer must not be nil")
+	}
+
+	return &ModeratedChatCompleter{
+		chatCompleter: opts.ChatCompleter,
+		moderator:     m,
+		skipInput:     opts.SkipInput,
+		skipOutput:    opts.SkipOutput,
+	}
+}
+
+// ChatComplete satisfies [gai.ChatCompleter].
+// Only the text of the latest user message is moderated before the request, since earlier messages have already been
+// screened in previous turns.
+// If output moderation is enabled, the response parts are buffered until the whole output has been moderated,
+// so nothing flagged is ever yielded.
+func (m *ModeratedChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	if !m.skipInput {
+		var text string
+		req.Messages, text = readLatestUserText(req.Messages)
+		if text != "" {
+			mod, err := m.moderator.Moderate(ctx, ModerateRequest{Text: text})
+			if err != nil {
+				return gai.ChatCompleteResponse{}, err
+			}
+			if mod.Flagged {
+				return gai.ChatCompleteResponse{}, &ModerationFlaggedError{Response: mod}
+			}
+		}
+	}
+
+	res, err := m.chatCompleter.ChatComplete(ctx, req)
+	if err != nil || m.skipOutput {
+		return res, err
+	}
+
+	moderated := gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		var parts []gai.MessagePart
+		var text strings.Builder
+		for part, err := range res.Parts() {
+			if err != nil {
+				yield(gai.MessagePart{}, err)
+				return
+			}
+			if part.Type == gai.MessagePartTypeText {
+				t := part.Text()
+				text.WriteString(t)
+				part = gai.TextMessagePart(t)
+			}
+			parts = append(parts, part)
+		}
+
+		if text.Len() > 0 {
+			mod, err := m.moderator.Moderate(ctx, ModerateRequest{Text: text.String()})
+			if err != nil {
+				yield(gai.MessagePart{}, err)
+				return
+			}
+			if mod.Flagged {
+				yield(gai.MessagePart{}, &ModerationFlaggedError{Output: true, Response: mod})
+				return
+			}
+		}
+
+		for _, part := range parts {
+			if !yield(part, nil) {
+				return
+			}
+		}
+	})
+	moderated.Meta = res.Meta
+
+	return moderated, nil
+}
+
+// readLatestUserText returns the concatenated text parts of the last message, if it's from the user.
+// Since message part content can only be read once, the messages are returned with the text parts of the last message
+// rebuilt from what was read, so they can still be sent to the chat completer. The given messages are not changed.
+func readLatestUserText(messages []gai.Message) ([]gai.Message, string) {
+	if len(messages) == 0 || messages[len(messages)-1].Role != gai.MessageRoleUser {
+		return messages, ""
+	}
+
+	last := messages[len(messages)-1]
+	parts := make([]gai.MessagePart, len(last.Parts))
+	var b strings.Builder
+	for i, part := range last.Parts {
+		if part.Type != gai.MessagePartTypeText {
+			parts[i] = part
+			continue
+		}
+
+		text := part.Text()
+		parts[i] = gai.TextMessagePart(text)
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(text)
+	}
+
+	messages = append(messages[:len(messages)-1:len(messages)-1], gai.Message{Role: last.Role, Parts: parts})
+	return messages, b.String()
+}
+
+var _ gai.ChatCompleter = (*ModeratedChatCompleter)(nil)
@@ -0,0 +1,280 @@
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestModerator_Moderate(t *testing.T) {
+	t.Run("can moderate a harmless text", func(t *testing.T) {
+		m := newModerator(t)
+
+		res, err := m.Moderate(t.Context(), openai.ModerateRequest{
+			Text: "I love puppies and sunny days.",
+		})
+		is.NotError(t, err)
+
+		is.True(t, !res.Flagged, "should not be flagged")
+		is.Equal(t, 0, len(res.FlaggedCategories()))
+		is.Equal(t, 13, len(res.Categories))
+	})
+
+	t.Run("can moderate a harmful text", func(t *testing.T) {
+		m := newModerator(t)
+
+		res, err := m.Moderate(t.Context(), openai.ModerateRequest{
+			Text: "I am going to find you and kill you, and then I will kill your whole family.",
+		})
+		is.NotError(t, err)
+
+		is.True(t, res.Flagged, "should be flagged")
+		is.True(t, res.Categories[openai.ModerationCategoryViolence].Flagged, "violence should be flagged")
+		is.True(t, res.Categories[openai.ModerationCategoryViolence].Score > 0.5, "violence score should be high")
+	})
+
+	t.Run("errors on empty input", func(t *testing.T) {
+		c := openai.NewClient(openai.NewClientOptions{Key: "test"})
+		m := c.NewModerator(openai.NewModeratorOptions{Model: openai.ModerateModelOmniModerationLatest})
+
+		_, err := m.Moderate(t.Context(), openai.ModerateRequest{})
+		is.True(t, err != nil, "should error")
+	})
+}
+
+func TestModeratedChatCompleter_ChatComplete(t *testing.T) {
+	t.Run("passes harmless input and output through", func(t *testing.T) {
+		m := newModerator(t)
+
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: &fakeChatCompleter{output: "Puppies are great."},
+		})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Tell me about puppies.")},
+		})
+		is.NotError(t, err)
+
+		var output string
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			output += part.Text()
+		}
+		is.Equal(t, "Puppies are great.", output)
+	})
+
+	t.Run("rejects harmful input before calling the chat completer", func(t *testing.T) {
+		m := newModerator(t)
+
+		fake := &fakeChatCompleter{output: "Okay."}
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: fake,
+		})
+
+		_, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("I am going to find you and kill you, and then I will kill your whole family.")},
+		})
+
+		var flaggedErr *openai.ModerationFlaggedError
+		is.True(t, errors.As(err, &flaggedErr), "should be a moderation flagged error")
+		is.True(t, !flaggedErr.Output, "should be flagged on input")
+		is.True(t, !fake.called, "chat completer should not be called")
+	})
+
+	t.Run("rejects harmful output", func(t *testing.T) {
+		m := newModerator(t)
+
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: &fakeChatCompleter{output: "I am going to find you and kill you, and then I will kill your whole family."},
+		})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var flaggedErr *openai.ModerationFlaggedError
+		for part, err := range res.Parts() {
+			if err != nil {
+				is.True(t, errors.As(err, &flaggedErr), "should be a moderation flagged error")
+				break
+			}
+			t.Fatalf("unexpected part before moderation error: %v", part.Text())
+		}
+		is.NotNil(t, flaggedErr, "should have gotten a moderation error")
+		is.True(t, flaggedErr.Output, "should be flagged on output")
+	})
+}
+
+func TestModeratedChatCompleter_ChatComplete_Offline(t *testing.T) {
+	t.Run("forwards the input text and yields the output text after moderating them", func(t *testing.T) {
+		m, moderated := newFakeModerator(t)
+
+		fake := &fakeChatCompleter{output: "Puppies are great."}
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: fake,
+		})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{
+				{Role: gai.MessageRoleUser, Parts: []gai.MessagePart{
+					gai.TextMessagePart("Tell me about puppies."),
+					gai.TextMessagePart("Be brief."),
+				}},
+			},
+		})
+		is.NotError(t, err)
+
+		var output string
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			output += part.Text()
+		}
+		is.Equal(t, "Puppies are great.", output)
+		is.Equal(t, "Tell me about puppies.Be brief.", fake.input)
+		is.EqualSlice(t, []string{"Tell me about puppies.\nBe brief.", "Puppies are great."}, *moderated)
+	})
+
+	t.Run("rejects flagged input before calling the chat completer", func(t *testing.T) {
+		m, _ := newFakeModerator(t)
+
+		fake := &fakeChatCompleter{output: "Okay."}
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: fake,
+		})
+
+		_, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("I will kill you.")},
+		})
+
+		var flaggedErr *openai.ModerationFlaggedError
+		is.True(t, errors.As(err, &flaggedErr), "should be a moderation flagged error")
+		is.True(t, !flaggedErr.Output, "should be flagged on input")
+		is.EqualSlice(t, []openai.ModerationCategory{openai.ModerationCategoryViolence}, flaggedErr.Response.FlaggedCategories())
+		is.True(t, !fake.called, "chat completer should not be called")
+	})
+
+	t.Run("rejects flagged output without yielding it", func(t *testing.T) {
+		m, _ := newFakeModerator(t)
+
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: &fakeChatCompleter{output: "I will kill you."},
+		})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var flaggedErr *openai.ModerationFlaggedError
+		for part, err := range res.Parts() {
+			if err != nil {
+				is.True(t, errors.As(err, &flaggedErr), "should be a moderation flagged error")
+				break
+			}
+			t.Fatalf("unexpected part before moderation error: %v", part.Text())
+		}
+		is.NotNil(t, flaggedErr, "should have gotten a moderation error")
+		is.True(t, flaggedErr.Output, "should be flagged on output")
+	})
+
+	t.Run("forwards the input unread if input moderation is skipped", func(t *testing.T) {
+		m, moderated := newFakeModerator(t)
+
+		fake := &fakeChatCompleter{output: "Puppies are great."}
+		cc := m.NewModeratedChatCompleter(openai.NewModeratedChatCompleterOptions{
+			ChatCompleter: fake,
+			SkipInput:     true,
+		})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Tell me about puppies.")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+		is.Equal(t, "Tell me about puppies.", fake.input)
+		is.EqualSlice(t, []string{"Puppies are great."}, *moderated)
+	})
+}
+
+func newModerator(t *testing.T) *openai.Moderator {
+	c := newClient(t)
+	return c.NewModerator(openai.NewModeratorOptions{
+		Model: openai.ModerateModelOmniModerationLatest,
+	})
+}
+
+// newFakeModerator returns a [openai.Moderator] against a test server that flags texts containing "kill" as violence,
+// and a pointer to the texts it has moderated.
+func newFakeModerator(t *testing.T) (*openai.Moderator, *[]string) {
+	t.Helper()
+
+	var moderated []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []struct {
+				Text string `json:"text"`
+			} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Input) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		text := req.Input[0].Text
+		moderated = append(moderated, text)
+
+		flagged := strings.Contains(text, "kill")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":    "modr-1",
+			"model": "omni-moderation-latest",
+			"results": []map[string]any{{
+				"flagged":                      flagged,
+				"categories":                   map[string]bool{"violence": flagged},
+				"category_scores":              map[string]float64{"violence": map[bool]float64{true: 0.9, false: 0.01}[flagged]},
+				"category_applied_input_types": map[string][]string{"violence": {"text"}},
+			}},
+		})
+	}))
+	t.Cleanup(s.Close)
+
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", Retry: openai.RetryOptions{MaxRetries: -1}})
+	return c.NewModerator(openai.NewModeratorOptions{Model: openai.ModerateModelOmniModerationLatest}), &moderated
+}
+
+// fakeChatCompleter records the text of the latest message it's called with, and yields the output in two parts.
+type fakeChatCompleter struct {
+	called bool
+	input  string
+	output string
+}
+
+func (f *fakeChatCompleter) ChatComplete(ctx context.Context, req gai.ChatCompleteRequest) (gai.ChatCompleteResponse, error) {
+	f.called = true
+	for _, part := range req.Messages[len(req.Messages)-1].Parts {
+		if part.Type == gai.MessagePartTypeText {
+			f.input += part.Text()
+		}
+	}
+
+	res := gai.NewChatCompleteResponse(func(yield func(gai.MessagePart, error) bool) {
+		half := len(f.output) / 2
+		if !yield(gai.TextMessagePart(f.output[:half]), nil) {
+			return
+		}
+		yield(gai.TextMessagePart(f.output[half:]), nil)
+	})
+	res.Meta = &gai.ChatCompleteResponseMetadata{}
+	return res, nil
+}
@@ -0,0 +1,349 @@
+package openaitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+)
+
+// RunChatCompleterConformance runs a standard battery of behavioral checks against a chat completer,
+// each as a subtest named after the feature it checks:
+// streaming, system_prompt, tool_round_trip, structured_output, usage, finish_reason, and cancellation.
+// Run it against a self-hosted model to see which features work, and skip the ones you don't need with go test -skip.
+//
+// The checks send real requests with simple prompts and temperature 0, so they need a reasonably capable model.
+func RunChatCompleterConformance(t *testing.T, cc gai.ChatCompleter) {
+	t.Helper()
+
+	t.Run("streaming", func(t *testing.T) {
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("Count from 1 to 10, separated by commas. Respond with nothing else.")},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		out := collect(res)
+		if out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		if out.textParts < 2 {
+			t.Errorf("expected the response to be streamed in several text parts, got %v", out.textParts)
+		}
+		if !strings.Contains(out.text, "10") {
+			t.Errorf("expected the response to contain 10, got %q", out.text)
+		}
+	})
+
+	t.Run("system_prompt", func(t *testing.T) {
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("Hi!")},
+			System:      gai.Ptr(`Respond with only the word "banana", whatever the user says.`),
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		out := collect(res)
+		if out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		if !strings.Contains(strings.ToLower(out.text), "banana") {
+			t.Errorf("expected the response to follow the system prompt, got %q", out.text)
+		}
+	})
+
+	t.Run("tool_round_trip", func(t *testing.T) {
+		tool := gai.Tool{
+			Name:        "get_secret_word",
+			Description: "Get the secret word.",
+			Schema:      gai.ToolSchema{Properties: map[string]*gai.Schema{}},
+			Execute: func(ctx context.Context, args json.RawMessage) (string, error) {
+				return "pineapple", nil
+			},
+		}
+
+		req := gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("What is the secret word? Use the tool to find out.")},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+			Tools:       []gai.Tool{tool},
+		}
+
+		res, err := cc.ChatComplete(t.Context(), req)
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		out := collect(res)
+		if out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		if len(out.toolCalls) != 1 {
+			t.Fatalf("expected one tool call, got %v", len(out.toolCalls))
+		}
+		toolCall := out.toolCalls[0]
+		if toolCall.Name != tool.Name {
+			t.Fatalf("expected a call to %v, got %v", tool.Name, toolCall.Name)
+		}
+		if toolCall.ID == "" {
+			t.Error("expected the tool call to have an ID")
+		}
+		checkFinishReason(t, res, gai.ChatCompleteFinishReasonToolCalls)
+
+		content, err := tool.Execute(t.Context(), toolCall.Args)
+		req.Messages = append(req.Messages,
+			gai.Message{Role: gai.MessageRoleModel, Parts: out.parts},
+			gai.NewUserToolResultMessage(gai.ToolResult{ID: toolCall.ID, Name: toolCall.Name, Content: content, Err: err}),
+		)
+
+		res, err = cc.ChatComplete(t.Context(), req)
+		if err != nil {
+			t.Fatal("error chat-completing with the tool result:", err)
+		}
+
+		out = collect(res)
+		if out.err != nil {
+			t.Fatal("error streaming with the tool result:", out.err)
+		}
+		if !strings.Contains(strings.ToLower(out.text), "pineapple") {
+			t.Errorf("expected the response to use the tool result, got %q", out.text)
+		}
+		checkFinishReason(t, res, gai.ChatCompleteFinishReasonStop)
+	})
+
+	t.Run("structured_output", func(t *testing.T) {
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("What is 2 + 2?")},
+			ResponseSchema: &gai.Schema{
+				Type:       gai.SchemaTypeObject,
+				Properties: map[string]*gai.Schema{"answer": {Type: gai.SchemaTypeInteger}},
+				Required:   []string{"answer"},
+			},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		out := collect(res)
+		if out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		var answer struct {
+			Answer *int `json:"answer"`
+		}
+		if err := json.Unmarshal([]byte(out.text), &answer); err != nil {
+			t.Fatalf("expected JSON output, got %q: %v", out.text, err)
+		}
+		if answer.Answer == nil || *answer.Answer != 4 {
+			t.Errorf("expected the answer 4, got %q", out.text)
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("Hi!")},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		if out := collect(res); out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		if res.Meta == nil {
+			t.Fatal("expected response metadata")
+		}
+		if res.Meta.Usage.PromptTokens <= 0 {
+			t.Errorf("expected prompt tokens, got %v", res.Meta.Usage.PromptTokens)
+		}
+		if res.Meta.Usage.CompletionTokens <= 0 {
+			t.Errorf("expected completion tokens, got %v", res.Meta.Usage.CompletionTokens)
+		}
+	})
+
+	t.Run("finish_reason", func(t *testing.T) {
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("Say hello.")},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		if out := collect(res); out.err != nil {
+			t.Fatal("error streaming:", out.err)
+		}
+		checkFinishReason(t, res, gai.ChatCompleteFinishReasonStop)
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		res, err := cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		if err == nil {
+			err = collect(res).err
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected a context.Canceled error for a canceled context, got %v", err)
+		}
+
+		ctx, cancel = context.WithCancel(t.Context())
+		defer cancel()
+
+		res, err = cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+			Messages:    []gai.Message{gai.NewUserTextMessage("Count from 1 to 200, separated by commas. Respond with nothing else.")},
+			Temperature: gai.Ptr(gai.Temperature(0)),
+		})
+		if err != nil {
+			t.Fatal("error chat-completing:", err)
+		}
+
+		var streamErr error
+		for _, err := range res.Parts() {
+			if err != nil {
+				streamErr = err
+				break
+			}
+			// Cancel mid-stream, after the first part
+			cancel()
+		}
+		if streamErr == nil {
+			t.Error("expected an error after canceling the context mid-stream")
+		}
+	})
+}
+
+// RunEmbedderConformance runs a standard battery of behavioral checks against an embedder,
+// each as a subtest named after the feature it checks: embedding, dimensions, similarity, and cancellation.
+// See [RunChatCompleterConformance].
+func RunEmbedderConformance(t *testing.T, e gai.Embedder[float64]) {
+	t.Helper()
+
+	embed := func(t *testing.T, input string) []float64 {
+		t.Helper()
+
+		res, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader(input)})
+		if err != nil {
+			t.Fatal("error embedding:", err)
+		}
+		return res.Embedding
+	}
+
+	t.Run("embedding", func(t *testing.T) {
+		embedding := embed(t, "The cat sat on the mat.")
+		if len(embedding) == 0 {
+			t.Fatal("expected an embedding")
+		}
+
+		var nonZero bool
+		for _, v := range embedding {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Fatalf("expected finite values, got %v", v)
+			}
+			if v != 0 {
+				nonZero = true
+			}
+		}
+		if !nonZero {
+			t.Error("expected a non-zero embedding")
+		}
+	})
+
+	t.Run("dimensions", func(t *testing.T) {
+		short := embed(t, "Hi!")
+		long := embed(t, strings.Repeat("The quick brown fox jumps over the lazy dog. ", 20))
+		if len(short) != len(long) {
+			t.Errorf("expected embeddings of the same dimensions, got %v and %v", len(short), len(long))
+		}
+	})
+
+	t.Run("similarity", func(t *testing.T) {
+		cat := embed(t, "The cat sat on the mat.")
+		similar := embed(t, "A cat was sitting on a mat.")
+		different := embed(t, "Quarterly revenue grew by five percent.")
+
+		if cosineSimilarity(cat, similar) <= cosineSimilarity(cat, different) {
+			t.Error("expected similar texts to have more similar embeddings than different texts")
+		}
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := e.Embed(ctx, gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected a context.Canceled error for a canceled context, got %v", err)
+		}
+	})
+}
+
+type collected struct {
+	err       error
+	parts     []gai.MessagePart
+	text      string
+	textParts int
+	toolCalls []gai.ToolCall
+}
+
+// collect all parts of a response, stopping at the first error.
+func collect(res gai.ChatCompleteResponse) collected {
+	var c collected
+	for part, err := range res.Parts() {
+		if err != nil {
+			c.err = err
+			return c
+		}
+
+		c.parts = append(c.parts, part)
+		switch part.Type {
+		case gai.MessagePartTypeText:
+			c.text += part.Text()
+			c.textParts++
+		case gai.MessagePartTypeToolCall:
+			c.toolCalls = append(c.toolCalls, part.ToolCall())
+		}
+	}
+	return c
+}
+
+func checkFinishReason(t *testing.T, res gai.ChatCompleteResponse, expected gai.ChatCompleteFinishReason) {
+	t.Helper()
+
+	if res.Meta == nil || res.Meta.FinishReason == nil {
+		t.Errorf("expected finish reason %v, got none", expected)
+		return
+	}
+	if *res.Meta.FinishReason != expected {
+		t.Errorf("expected finish reason %v, got %v", expected, *res.Meta.FinishReason)
+	}
+}
+
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,80 @@
+package openaitest_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	openai "maragu.dev/gai-openai"
+	"maragu.dev/gai-openai/openaitest"
+)
+
+func TestRunChatCompleterConformance(t *testing.T) {
+	s := openaitest.NewServer(t)
+
+	var count []string
+	for i := range 200 {
+		count = append(count, strconv.Itoa(i+1)+", ")
+	}
+
+	// Responses in the order the conformance checks send requests
+	s.AddChatResponse(
+		// streaming
+		openaitest.ChatResponse{Text: []string{"1, 2, 3, 4, 5, ", "6, 7, 8, 9, 10"}},
+		// system_prompt
+		openaitest.ChatResponse{Text: []string{"banana"}},
+		// tool_round_trip
+		openaitest.ChatResponse{ToolCalls: []openaitest.ToolCall{{ID: "call_1", Name: "get_secret_word", Arguments: "{}"}}},
+		openaitest.ChatResponse{Text: []string{"The secret word is ", "pineapple."}},
+		// structured_output
+		openaitest.ChatResponse{Text: []string{`{"answer":`, `4}`}},
+		// usage
+		openaitest.ChatResponse{Text: []string{"Hello!"}, Usage: &openaitest.Usage{PromptTokens: 9, CompletionTokens: 2}},
+		// finish_reason
+		openaitest.ChatResponse{Text: []string{"Hello!"}},
+		// cancellation, mid-stream
+		openaitest.ChatResponse{Text: count, ChunkDelay: 10 * time.Millisecond},
+	)
+
+	c := openai.NewClient(openai.NewClientOptions{
+		BaseURL: s.URL,
+		Key:     "test",
+		Retry:   openai.RetryOptions{MaxRetries: -1},
+	})
+
+	openaitest.RunChatCompleterConformance(t, c.NewChatCompleter(openai.NewChatCompleterOptions{
+		Model: openai.ChatCompleteModelGPT4oMini,
+	}))
+
+	// The tool result is sent back with the tool call
+	requests := s.Requests()
+	messages := requests[3].Messages
+	last := messages[len(messages)-1]
+	if last.Role != "tool" || last.ToolCallID != "call_1" || last.Content != "pineapple" {
+		t.Errorf("unexpected tool result message: %+v", last)
+	}
+}
+
+func TestRunEmbedderConformance(t *testing.T) {
+	s := openaitest.NewServer(t)
+
+	// Responses in the order the conformance checks send requests
+	s.AddEmbedResponse(
+		// embedding
+		openaitest.EmbedResponse{Embedding: []float64{0.1, 0.2, 0.3}},
+		// dimensions
+		openaitest.EmbedResponse{Embedding: []float64{0.3, 0.2, 0.1}},
+		openaitest.EmbedResponse{Embedding: []float64{0.2, 0.2, 0.2}},
+		// similarity
+		openaitest.EmbedResponse{Embedding: []float64{1, 0, 0}},
+		openaitest.EmbedResponse{Embedding: []float64{0.9, 0.1, 0}},
+		openaitest.EmbedResponse{Embedding: []float64{0, 0, 1}},
+	)
+
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+
+	openaitest.RunEmbedderConformance(t, c.NewEmbedder(openai.NewEmbedderOptions{
+		Model:      openai.EmbedModelTextEmbedding3Small,
+		Dimensions: 3,
+	}))
+}
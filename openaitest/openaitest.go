@@ -0,0 +1,440 @@
+// Package openaitest provides a fake OpenAI API server for tests.
+// It speaks the chat completions endpoint (streaming only) and the embeddings endpoint,
+// and responds with scripted responses in the order they were added.
+//
+// Point NewClientOptions.BaseURL at [Server.URL] and assert on [Server.Requests] afterwards.
+package openaitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is a fake OpenAI API server. It's safe for concurrent use.
+type Server struct {
+	*httptest.Server
+	chatResponses  []ChatResponse
+	embedResponses []EmbedResponse
+	lock           sync.Mutex
+	requests       []Request
+}
+
+// NewServer starts a [Server], which is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// ChatResponse is a scripted response to a chat completion request.
+// Text deltas are streamed first, then the refusal, then tool calls, then the finish reason, usage, and stream error.
+type ChatResponse struct {
+	// Delay before responding.
+	Delay time.Duration
+	// ChunkDelay between each streamed chunk.
+	ChunkDelay time.Duration
+	// Error is returned as an HTTP error response instead of streaming.
+	Error *Error
+	// FinishReason of the response. Defaults to "tool_calls" if there are tool calls, and "stop" otherwise.
+	FinishReason string
+	// Model in the response. Defaults to the model in the request.
+	Model string
+	// Refusal by the model.
+	Refusal string
+	// StreamError is sent as an error event at the end of the stream.
+	StreamError *Error
+	// Text deltas, streamed one chunk each.
+	Text      []string
+	ToolCalls []ToolCall
+	// Usage is sent in a final chunk, if set.
+	Usage *Usage
+}
+
+// EmbedResponse is a scripted response to an embedding request.
+type EmbedResponse struct {
+	// Delay before responding.
+	Delay     time.Duration
+	Embedding []float64
+	// Error is returned as an HTTP error response.
+	Error        *Error
+	PromptTokens int
+}
+
+// ToolCall by the model.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Usage of tokens in a chat completion response.
+type Usage struct {
+	PromptTokens       int
+	CachedPromptTokens int
+	CompletionTokens   int
+	ReasoningTokens    int
+}
+
+// Error response from the API.
+type Error struct {
+	// StatusCode of the HTTP error response. Defaults to 500. Not used for stream errors.
+	StatusCode int
+	// Headers of the HTTP error response, such as retry-after.
+	Headers map[string]string
+	Code    string
+	Message string
+	Param   string
+	Type    string
+}
+
+// Request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+	// Model in the request body.
+	Model string
+	// Messages in a chat completion request body.
+	Messages []Message
+	// Input in an embedding request body, if it's a string.
+	Input string
+}
+
+// Message in a chat completion request.
+type Message struct {
+	Role string
+	// Content of the message. Text parts are concatenated.
+	Content    string
+	ToolCallID string
+	ToolCalls  []ToolCall
+}
+
+// AddChatResponse scripts responses to the next chat completion requests, in order.
+func (s *Server) AddChatResponse(responses ...ChatResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.chatResponses = append(s.chatResponses, responses...)
+}
+
+// AddEmbedResponse scripts responses to the next embedding requests, in order.
+func (s *Server) AddEmbedResponse(responses ...EmbedResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.embedResponses = append(s.embedResponses, responses...)
+}
+
+// Requests received so far.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, Error{StatusCode: http.StatusBadRequest, Message: "error reading body: " + err.Error()})
+		return
+	}
+
+	req := newRequest(r, body)
+
+	s.lock.Lock()
+	s.requests = append(s.requests, req)
+	s.lock.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		res, ok := s.nextChatResponse()
+		if !ok {
+			writeError(w, Error{StatusCode: http.StatusBadRequest, Message: "openaitest: no scripted chat response left"})
+			return
+		}
+		writeChatResponse(w, r, req, res)
+
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/embeddings"):
+		res, ok := s.nextEmbedResponse()
+		if !ok {
+			writeError(w, Error{StatusCode: http.StatusBadRequest, Message: "openaitest: no scripted embed response left"})
+			return
+		}
+		writeEmbedResponse(w, r, req, res)
+
+	default:
+		writeError(w, Error{StatusCode: http.StatusNotFound, Message: "openaitest: unknown endpoint " + r.Method + " " + r.URL.Path})
+	}
+}
+
+func (s *Server) nextChatResponse() (ChatResponse, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.chatResponses) == 0 {
+		return ChatResponse{}, false
+	}
+	res := s.chatResponses[0]
+	s.chatResponses = s.chatResponses[1:]
+	return res, true
+}
+
+func (s *Server) nextEmbedResponse() (EmbedResponse, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.embedResponses) == 0 {
+		return EmbedResponse{}, false
+	}
+	res := s.embedResponses[0]
+	s.embedResponses = s.embedResponses[1:]
+	return res, true
+}
+
+func writeChatResponse(w http.ResponseWriter, r *http.Request, req Request, res ChatResponse) {
+	if !sleep(r, res.Delay) {
+		return
+	}
+
+	if res.Error != nil {
+		writeError(w, *res.Error)
+		return
+	}
+
+	model := res.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	finishReason := res.FinishReason
+	if finishReason == "" {
+		finishReason = "stop"
+		if len(res.ToolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+	}
+
+	var chunks []any
+	chunks = append(chunks, chunk(model, map[string]any{"delta": map[string]any{"role": "assistant", "content": ""}}))
+	for _, text := range res.Text {
+		chunks = append(chunks, chunk(model, map[string]any{"delta": map[string]any{"content": text}}))
+	}
+	if res.Refusal != "" {
+		chunks = append(chunks, chunk(model, map[string]any{"delta": map[string]any{"refusal": res.Refusal}}))
+	}
+	for i, toolCall := range res.ToolCalls {
+		chunks = append(chunks, chunk(model, map[string]any{"delta": map[string]any{"tool_calls": []any{map[string]any{
+			"index": i,
+			"id":    toolCall.ID,
+			"type":  "function",
+			"function": map[string]any{
+				"name":      toolCall.Name,
+				"arguments": toolCall.Arguments,
+			},
+		}}}}))
+	}
+	chunks = append(chunks, chunk(model, map[string]any{"delta": map[string]any{}, "finish_reason": finishReason}))
+	if res.Usage != nil {
+		c := chunk(model)
+		c["usage"] = map[string]any{
+			"prompt_tokens":             res.Usage.PromptTokens,
+			"completion_tokens":         res.Usage.CompletionTokens,
+			"total_tokens":              res.Usage.PromptTokens + res.Usage.CompletionTokens,
+			"prompt_tokens_details":     map[string]any{"cached_tokens": res.Usage.CachedPromptTokens},
+			"completion_tokens_details": map[string]any{"reasoning_tokens": res.Usage.ReasoningTokens},
+		}
+		chunks = append(chunks, c)
+	}
+	if res.StreamError != nil {
+		chunks = append(chunks, map[string]any{"error": errorObject(*res.StreamError)})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Request-Id", "req_openaitest")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	for i, c := range chunks {
+		if i > 0 && !sleep(r, res.ChunkDelay) {
+			return
+		}
+		b, err := json.Marshal(c)
+		if err != nil {
+			panic(err)
+		}
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", b)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if res.StreamError == nil {
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}
+}
+
+// chunk of a streamed chat completion, with the given choices.
+func chunk(model string, choices ...map[string]any) map[string]any {
+	cs := []any{}
+	for _, choice := range choices {
+		choice["index"] = 0
+		cs = append(cs, choice)
+	}
+	return map[string]any{
+		"id":      "chatcmpl-openaitest",
+		"object":  "chat.completion.chunk",
+		"created": 0,
+		"model":   model,
+		"choices": cs,
+	}
+}
+
+func writeEmbedResponse(w http.ResponseWriter, r *http.Request, req Request, res EmbedResponse) {
+	if !sleep(r, res.Delay) {
+		return
+	}
+
+	if res.Error != nil {
+		writeError(w, *res.Error)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", "req_openaitest")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"data": []any{map[string]any{
+			"object":    "embedding",
+			"index":     0,
+			"embedding": res.Embedding,
+		}},
+		"model": req.Model,
+		"usage": map[string]any{
+			"prompt_tokens": res.PromptTokens,
+			"total_tokens":  res.PromptTokens,
+		},
+	})
+}
+
+func writeError(w http.ResponseWriter, e Error) {
+	if e.StatusCode == 0 {
+		e.StatusCode = http.StatusInternalServerError
+	}
+	for k, v := range e.Headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", "req_openaitest")
+	w.WriteHeader(e.StatusCode)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": errorObject(e)})
+}
+
+func errorObject(e Error) map[string]any {
+	o := map[string]any{
+		"message": e.Message,
+		"type":    e.Type,
+		"param":   nil,
+		"code":    nil,
+	}
+	if e.Param != "" {
+		o["param"] = e.Param
+	}
+	if e.Code != "" {
+		o["code"] = e.Code
+	}
+	return o
+}
+
+// sleep for the duration, and return false if the request was cancelled in the meantime.
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// newRequest with the fields of a JSON body decoded, if possible.
+func newRequest(r *http.Request, body []byte) Request {
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	var decoded struct {
+		Model    string `json:"model"`
+		Input    any    `json:"input"`
+		Messages []struct {
+			Role       string          `json:"role"`
+			Content    json.RawMessage `json:"content"`
+			ToolCallID string          `json:"tool_call_id"`
+			ToolCalls  []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return req
+	}
+
+	req.Model = decoded.Model
+	if input, ok := decoded.Input.(string); ok {
+		req.Input = input
+	}
+
+	for _, m := range decoded.Messages {
+		message := Message{Role: m.Role, Content: contentText(m.Content), ToolCallID: m.ToolCallID}
+		for _, toolCall := range m.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, ToolCall{
+				ID:        toolCall.ID,
+				Name:      toolCall.Function.Name,
+				Arguments: toolCall.Function.Arguments,
+			})
+		}
+		req.Messages = append(req.Messages, message)
+	}
+
+	return req
+}
+
+// contentText of message content, which is either a string or an array of content parts.
+func contentText(content json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		return s
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, part := range parts {
+		if part.Type == "text" {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,205 @@
+package openaitest_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+	"maragu.dev/gai-openai/openaitest"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("streams scripted text and usage, and records requests", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddChatResponse(openaitest.ChatResponse{
+			Text:  []string{"Hello", ", world!"},
+			Usage: &openaitest.Usage{PromptTokens: 10, CompletionTokens: 3},
+		})
+
+		cc := newChatCompleter(s)
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			System:   gai.Ptr("You are a helpful assistant."),
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var text strings.Builder
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			text.WriteString(part.Text())
+		}
+		is.Equal(t, "Hello, world!", text.String())
+		is.Equal(t, gai.ChatCompleteFinishReasonStop, *res.Meta.FinishReason)
+		is.Equal(t, 10, res.Meta.Usage.PromptTokens)
+		is.Equal(t, 3, res.Meta.Usage.CompletionTokens)
+
+		requests := s.Requests()
+		is.Equal(t, 1, len(requests))
+		is.Equal(t, "gpt-4o-mini", requests[0].Model)
+		is.Equal(t, 2, len(requests[0].Messages))
+		is.Equal(t, "system", requests[0].Messages[0].Role)
+		is.Equal(t, "You are a helpful assistant.", requests[0].Messages[0].Content)
+		is.Equal(t, "user", requests[0].Messages[1].Role)
+		is.Equal(t, "Hi!", requests[0].Messages[1].Content)
+		is.Equal(t, "Bearer test", requests[0].Header.Get("Authorization"))
+	})
+
+	t.Run("streams scripted tool calls", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddChatResponse(openaitest.ChatResponse{
+			ToolCalls: []openaitest.ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.txt"}`},
+				{ID: "call_2", Name: "read_file", Arguments: `{"path":"b.txt"}`},
+			},
+		})
+
+		res, err := newChatCompleter(s).ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Read the files.")},
+		})
+		is.NotError(t, err)
+
+		var toolCalls []gai.ToolCall
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			if part.Type == gai.MessagePartTypeToolCall {
+				toolCalls = append(toolCalls, part.ToolCall())
+			}
+		}
+		is.Equal(t, 2, len(toolCalls))
+		is.Equal(t, "call_1", toolCalls[0].ID)
+		is.Equal(t, "read_file", toolCalls[0].Name)
+		is.Equal(t, `{"path":"a.txt"}`, string(toolCalls[0].Args))
+		is.Equal(t, "call_2", toolCalls[1].ID)
+		is.Equal(t, gai.ChatCompleteFinishReasonToolCalls, *res.Meta.FinishReason)
+	})
+
+	t.Run("streams scripted refusals", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddChatResponse(openaitest.ChatResponse{Refusal: "I can't help with that."})
+
+		res, err := newChatCompleter(s).ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var partErr error
+		for _, err := range res.Parts() {
+			if err != nil {
+				partErr = err
+			}
+		}
+		is.True(t, partErr != nil, "should error")
+		is.True(t, strings.Contains(partErr.Error(), "I can't help with that."), partErr.Error())
+		is.Equal(t, gai.ChatCompleteFinishReasonRefusal, *res.Meta.FinishReason)
+	})
+
+	t.Run("returns scripted errors and stream errors", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddChatResponse(
+			openaitest.ChatResponse{Error: &openaitest.Error{
+				StatusCode: 429,
+				Code:       "rate_limit_exceeded",
+				Message:    "Rate limit reached.",
+				Type:       "requests",
+			}},
+			openaitest.ChatResponse{
+				Text:        []string{"Hel"},
+				StreamError: &openaitest.Error{Message: "The server had an error.", Type: "server_error"},
+			},
+		)
+
+		cc := newChatCompleter(s)
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		var rateLimitErr *openai.RateLimitError
+		for _, err := range res.Parts() {
+			if err != nil {
+				is.True(t, errors.As(err, &rateLimitErr), "should be a rate limit error")
+			}
+		}
+		is.NotNil(t, rateLimitErr)
+
+		res, err = cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		var serverErr *openai.ServerError
+		for _, err := range res.Parts() {
+			if err != nil {
+				is.True(t, errors.As(err, &serverErr), "should be a server error")
+			}
+		}
+		is.NotNil(t, serverErr)
+	})
+
+	t.Run("errors if there are no scripted responses left", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+
+		res, err := newChatCompleter(s).ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		var partErr error
+		for _, err := range res.Parts() {
+			if err != nil {
+				partErr = err
+			}
+		}
+		is.True(t, partErr != nil, "should error")
+		is.True(t, strings.Contains(partErr.Error(), "no scripted chat response left"), partErr.Error())
+	})
+
+	t.Run("delays responses", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddChatResponse(openaitest.ChatResponse{Delay: time.Second, Text: []string{"Hello!"}})
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		res, err := newChatCompleter(s).ChatComplete(ctx, gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		var partErr error
+		for _, err := range res.Parts() {
+			if err != nil {
+				partErr = err
+			}
+		}
+		is.True(t, errors.Is(partErr, context.DeadlineExceeded), "should time out")
+	})
+
+	t.Run("responds with scripted embeddings", func(t *testing.T) {
+		s := openaitest.NewServer(t)
+		s.AddEmbedResponse(openaitest.EmbedResponse{Embedding: []float64{0.1, 0.2, 0.3}, PromptTokens: 1})
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		res, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.EqualSlice(t, []float64{0.1, 0.2, 0.3}, res.Embedding)
+
+		requests := s.Requests()
+		is.Equal(t, 1, len(requests))
+		is.Equal(t, "Hi!", requests[0].Input)
+		is.Equal(t, "text-embedding-3-small", requests[0].Model)
+	})
+}
+
+func newChatCompleter(s *openaitest.Server) *openai.ChatCompleter {
+	c := openai.NewClient(openai.NewClientOptions{
+		BaseURL: s.URL,
+		Key:     "test",
+		Retry:   openai.RetryOptions{MaxRetries: -1},
+	})
+	return c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+}
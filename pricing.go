@@ -0,0 +1,129 @@
+package openai
+
+import (
+	"sync"
+
+	"maragu.dev/gai"
+)
+
+// Price of a model in US dollars per million tokens.
+type Price struct {
+	Input float64
+	// CachedInput is the price of prompt tokens read from the prompt cache. Defaults to Input if zero.
+	CachedInput float64
+	Output      float64
+	// Reasoning is the price of reasoning tokens, which are part of the completion tokens. Defaults to Output if zero.
+	Reasoning float64
+}
+
+// Usage of tokens by one or more requests, and the estimated cost.
+type Usage struct {
+	// Requests the usage is for.
+	Requests     int
+	PromptTokens int
+	// CachedPromptTokens is the part of PromptTokens read from the prompt cache.
+	CachedPromptTokens int
+	CompletionTokens   int
+	// ReasoningTokens is the part of CompletionTokens used for reasoning.
+	ReasoningTokens int
+	// AudioPromptTokens is the part of PromptTokens that is audio input.
+	AudioPromptTokens int
+	// AudioCompletionTokens is the part of CompletionTokens that is audio output.
+	AudioCompletionTokens int
+	// AcceptedPredictionTokens are tokens from a predicted output that appeared in the completion.
+	AcceptedPredictionTokens int
+	// RejectedPredictionTokens are tokens from a predicted output that didn't appear in the completion.
+	// They are billed as completion tokens.
+	RejectedPredictionTokens int
+	// Cost in US dollars, estimated from the registered [Price] of the model. It's zero if the price is unknown.
+	Cost float64
+}
+
+// Add other usage to this one.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Requests:                 u.Requests + other.Requests,
+		PromptTokens:             u.PromptTokens + other.PromptTokens,
+		CachedPromptTokens:       u.CachedPromptTokens + other.CachedPromptTokens,
+		CompletionTokens:         u.CompletionTokens + other.CompletionTokens,
+		ReasoningTokens:          u.ReasoningTokens + other.ReasoningTokens,
+		AudioPromptTokens:        u.AudioPromptTokens + other.AudioPromptTokens,
+		AudioCompletionTokens:    u.AudioCompletionTokens + other.AudioCompletionTokens,
+		AcceptedPredictionTokens: u.AcceptedPredictionTokens + other.AcceptedPredictionTokens,
+		RejectedPredictionTokens: u.RejectedPredictionTokens + other.RejectedPredictionTokens,
+		Cost:                     u.Cost + other.Cost,
+	}
+}
+
+// Cost in US dollars of the given token usage. The Cost field of the usage is ignored.
+func (p Price) Cost(u Usage) float64 {
+	cachedInput := p.CachedInput
+	if cachedInput == 0 {
+		cachedInput = p.Input
+	}
+	reasoning := p.Reasoning
+	if reasoning == 0 {
+		reasoning = p.Output
+	}
+
+	cost := float64(u.PromptTokens-u.CachedPromptTokens)*p.Input +
+		float64(u.CachedPromptTokens)*cachedInput +
+		float64(u.CompletionTokens-u.ReasoningTokens)*p.Output +
+		float64(u.ReasoningTokens)*reasoning
+	return cost / 1_000_000
+}
+
+var pricesLock sync.RWMutex
+
+// prices for standard processing, from https://platform.openai.com/docs/pricing
+var prices = map[string]Price{
+	string(ChatCompleteModelGPT4o): {
+		Input:       2.50,
+		CachedInput: 1.25,
+		Output:      10.00,
+	},
+	string(ChatCompleteModelGPT4oMini): {
+		Input:       0.15,
+		CachedInput: 0.075,
+		Output:      0.60,
+	},
+	string(EmbedModelTextEmbedding3Large): {
+		Input: 0.13,
+	},
+	string(EmbedModelTextEmbedding3Small): {
+		Input: 0.02,
+	},
+}
+
+// RegisterPrice of a chat completion or embedding model, for models this package doesn't know about,
+// such as fine-tuned or self-hosted models. Registering a known model overrides its built-in price.
+func RegisterPrice(model string, price Price) {
+	pricesLock.Lock()
+	defer pricesLock.Unlock()
+	prices[model] = price
+}
+
+// GetPrice returns the price for the model, and false if the model is unknown.
+func GetPrice(model string) (Price, bool) {
+	pricesLock.RLock()
+	defer pricesLock.RUnlock()
+	price, ok := prices[model]
+	return price, ok
+}
+
+// EstimateCost in US dollars of the given usage of the model, and false if the price of the model is unknown.
+func EstimateCost(model string, u Usage) (float64, bool) {
+	price, ok := GetPrice(model)
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(u), true
+}
+
+// ChatCompleteCost estimates the cost in US dollars of a [gai.ChatCompleteResponse] from its usage metadata,
+// and returns false if the price of the model is unknown.
+// The metadata doesn't have cached prompt tokens, so all prompt tokens are priced as uncached.
+// Use [WithUsageInto] or a [UsageAccumulator] for costs that take the prompt cache into account.
+func ChatCompleteCost(model ChatCompleteModel, usage gai.ChatCompleteResponseUsage) (float64, bool) {
+	return EstimateCost(string(model), Usage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens})
+}
@@ -0,0 +1,58 @@
+package openai_test
+
+import (
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestPrice_Cost(t *testing.T) {
+	t.Run("prices cached prompt tokens and reasoning tokens separately", func(t *testing.T) {
+		p := openai.Price{Input: 2, CachedInput: 1, Output: 8, Reasoning: 16}
+		cost := p.Cost(openai.Usage{
+			PromptTokens:       1_000_000,
+			CachedPromptTokens: 500_000,
+			CompletionTokens:   1_000_000,
+			ReasoningTokens:    250_000,
+		})
+		is.Equal(t, 0.5*2+0.5*1+0.75*8+0.25*16, cost)
+	})
+
+	t.Run("defaults cached input to input and reasoning to output", func(t *testing.T) {
+		p := openai.Price{Input: 2, Output: 8}
+		cost := p.Cost(openai.Usage{
+			PromptTokens:       1_000_000,
+			CachedPromptTokens: 500_000,
+			CompletionTokens:   1_000_000,
+			ReasoningTokens:    250_000,
+		})
+		is.Equal(t, 10.0, cost)
+	})
+}
+
+func TestGetPrice(t *testing.T) {
+	t.Run("returns prices for known models", func(t *testing.T) {
+		p, ok := openai.GetPrice(string(openai.ChatCompleteModelGPT4oMini))
+		is.True(t, ok)
+		is.Equal(t, 0.15, p.Input)
+	})
+
+	t.Run("returns false for unknown models", func(t *testing.T) {
+		_, ok := openai.GetPrice("unknown")
+		is.True(t, !ok)
+	})
+
+	t.Run("returns registered prices for custom models", func(t *testing.T) {
+		openai.RegisterPrice("ft:gpt-4o-mini:test-pricing", openai.Price{Input: 0.3, Output: 1.2})
+
+		cost, ok := openai.ChatCompleteCost("ft:gpt-4o-mini:test-pricing", gai.ChatCompleteResponseUsage{
+			PromptTokens:     1_000_000,
+			CompletionTokens: 1_000_000,
+		})
+		is.True(t, ok)
+		is.Equal(t, 1.5, cost)
+	})
+}
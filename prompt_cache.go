@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"maragu.dev/gai"
+)
+
+// PromptCacheRetention is how long cached prompt prefixes are kept.
+type PromptCacheRetention string
+
+const (
+	PromptCacheRetentionInMemory = PromptCacheRetention("in-memory")
+	PromptCacheRetention24h      = PromptCacheRetention("24h")
+)
+
+// PromptCacheOptions for prompt caching, which makes requests with a previously seen prompt prefix cheaper and faster.
+// See https://platform.openai.com/docs/guides/prompt-caching
+type PromptCacheOptions struct {
+	// Key is sent as prompt_cache_key, so requests with the same key and a shared prompt prefix are more likely
+	// to hit the same cache. Use it for requests that share a large system prompt, for example.
+	Key string
+	// Retention of cached prompt prefixes. Defaults to the API default.
+	Retention PromptCacheRetention
+}
+
+type promptCacheContextKey struct{}
+
+// WithPromptCache returns a context that makes [ChatCompleter.ChatComplete] use the given prompt cache options.
+// Non-empty fields override the PromptCache options of the chat completer.
+func WithPromptCache(ctx context.Context, opts PromptCacheOptions) context.Context {
+	return context.WithValue(ctx, promptCacheContextKey{}, opts)
+}
+
+func promptCacheFromContext(ctx context.Context) PromptCacheOptions {
+	opts, _ := ctx.Value(promptCacheContextKey{}).(PromptCacheOptions)
+	return opts
+}
+
+// apply the non-empty prompt cache options to the request parameters.
+func (o PromptCacheOptions) apply(params *openai.ChatCompletionNewParams) {
+	if o.Key != "" {
+		params.PromptCacheKey = openai.String(o.Key)
+	}
+	if o.Retention != "" {
+		// The SDK doesn't have a field for the retention yet
+		params.SetExtraFields(map[string]any{"prompt_cache_retention": string(o.Retention)})
+	}
+}
+
+// PrepareForPromptCache returns a copy of the request with the tools sorted by name, so requests with the same tools
+// share a cached prompt prefix even if the tools are added in a different order, for example from a map.
+// Nothing else is changed. The tools and the system prompt are always sent before the messages,
+// so keep the system prompt stable and put anything that changes between requests, such as the current time,
+// in the messages instead.
+func PrepareForPromptCache(req gai.ChatCompleteRequest) gai.ChatCompleteRequest {
+	req.Tools = slices.Clone(req.Tools)
+	slices.SortStableFunc(req.Tools, func(a, b gai.Tool) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return req
+}
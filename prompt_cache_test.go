@@ -0,0 +1,134 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestChatCompleter_PromptCache(t *testing.T) {
+	t.Run("sends the prompt cache key and retention, overridden from the context", func(t *testing.T) {
+		var bodies []map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+			bodies = append(bodies, body)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:        s.URL,
+			Key:            "test",
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{
+			Model: openai.ChatCompleteModelGPT4oMini,
+			PromptCache: openai.PromptCacheOptions{
+				Key:       "support-bot",
+				Retention: openai.PromptCacheRetention24h,
+			},
+		})
+
+		req := gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Hi!")}}
+
+		res, err := cc.ChatComplete(t.Context(), req)
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		res, err = cc.ChatComplete(openai.WithPromptCache(t.Context(), openai.PromptCacheOptions{Key: "tenant-a"}), req)
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		is.Equal(t, 2, len(bodies))
+		is.Equal(t, "support-bot", bodies[0]["prompt_cache_key"].(string))
+		is.Equal(t, "24h", bodies[0]["prompt_cache_retention"].(string))
+		is.Equal(t, "tenant-a", bodies[1]["prompt_cache_key"].(string))
+		is.Equal(t, "24h", bodies[1]["prompt_cache_retention"].(string))
+
+		spans := recorder.Ended()
+		is.Equal(t, 2, len(spans))
+		is.Equal(t, "support-bot", attributesOf(spans[0])["gen_ai.openai.request.prompt_cache_key"].AsString())
+		is.Equal(t, "tenant-a", attributesOf(spans[1])["gen_ai.openai.request.prompt_cache_key"].AsString())
+	})
+
+	t.Run("does not send prompt cache options by default", func(t *testing.T) {
+		var body map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{Messages: []gai.Message{gai.NewUserTextMessage("Hi!")}})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		_, ok := body["prompt_cache_key"]
+		is.True(t, !ok, "should not have prompt_cache_key")
+		_, ok = body["prompt_cache_retention"]
+		is.True(t, !ok, "should not have prompt_cache_retention")
+	})
+}
+
+func TestPrepareForPromptCache(t *testing.T) {
+	t.Run("sorts tools by name without changing the original request", func(t *testing.T) {
+		req := gai.ChatCompleteRequest{
+			Tools: []gai.Tool{{Name: "read_file"}, {Name: "list_dir"}, {Name: "edit_file"}},
+		}
+
+		prepared := openai.PrepareForPromptCache(req)
+
+		is.Equal(t, "edit_file", prepared.Tools[0].Name)
+		is.Equal(t, "list_dir", prepared.Tools[1].Name)
+		is.Equal(t, "read_file", prepared.Tools[2].Name)
+		is.Equal(t, "read_file", req.Tools[0].Name)
+	})
+
+	t.Run("sends the same tools and system prompt regardless of the tool order", func(t *testing.T) {
+		cc := newOfflineChatCompleter()
+		newParams := func(toolNames ...string) []byte {
+			req := gai.ChatCompleteRequest{
+				Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+				System:   gai.Ptr("You are a helpful assistant."),
+			}
+			for _, name := range toolNames {
+				req.Tools = append(req.Tools, gai.Tool{Name: name, Schema: gai.ToolSchema{Properties: map[string]*gai.Schema{}}})
+			}
+
+			params, err := cc.NewParams(openai.PrepareForPromptCache(req))
+			is.NotError(t, err)
+			b, err := json.Marshal(params)
+			is.NotError(t, err)
+			return b
+		}
+
+		is.Equal(t, string(newParams("read_file", "list_dir")), string(newParams("list_dir", "read_file")))
+	})
+}
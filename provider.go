@@ -0,0 +1,80 @@
+package openai
+
+import (
+	"maragu.dev/gai"
+)
+
+// Provider of an OpenAI-compatible API, used to pick a base URL and [Compatibility] preset in [NewClientOptions].
+type Provider string
+
+const (
+	ProviderOpenAI     = Provider("")
+	ProviderGroq       = Provider("groq")
+	ProviderLlamaCPP   = Provider("llama.cpp")
+	ProviderLMStudio   = Provider("lm-studio")
+	ProviderOllama     = Provider("ollama")
+	ProviderOpenRouter = Provider("openrouter")
+	ProviderTogether   = Provider("together")
+	ProviderVLLM       = Provider("vllm")
+)
+
+// Compatibility toggles request and response behavior for OpenAI-compatible servers that differ from the OpenAI API.
+type Compatibility struct {
+	// NoStreamOptions doesn't send stream_options to ask for token usage in streams.
+	// Servers that don't support it may still report usage.
+	NoStreamOptions bool
+	// NoJSONSchema sends structured output requests with the json_object response format instead of json_schema,
+	// and adds the schema to the system prompt instead.
+	NoJSONSchema bool
+	// NoDimensions doesn't send the dimensions parameter when embedding, so the model's native dimensions are used.
+	NoDimensions bool
+	// FinishReasons maps non-standard finish reasons to gai finish reasons.
+	FinishReasons map[string]gai.ChatCompleteFinishReason
+}
+
+type providerPreset struct {
+	baseURL       string
+	compatibility Compatibility
+}
+
+var providerPresets = map[Provider]providerPreset{
+	ProviderOpenAI: {},
+	ProviderGroq: {
+		baseURL:       "https://api.groq.com/openai/v1/",
+		compatibility: Compatibility{NoJSONSchema: true},
+	},
+	ProviderLlamaCPP: {
+		baseURL:       "http://localhost:8080/v1/",
+		compatibility: Compatibility{NoDimensions: true},
+	},
+	ProviderLMStudio: {
+		baseURL:       "http://localhost:1234/v1/",
+		compatibility: Compatibility{NoStreamOptions: true, NoDimensions: true},
+	},
+	ProviderOllama: {
+		baseURL:       "http://localhost:11434/v1/",
+		compatibility: Compatibility{NoDimensions: true},
+	},
+	ProviderOpenRouter: {
+		baseURL: "https://openrouter.ai/api/v1/",
+	},
+	ProviderTogether: {
+		baseURL: "https://api.together.xyz/v1/",
+		compatibility: Compatibility{
+			NoDimensions:  true,
+			FinishReasons: map[string]gai.ChatCompleteFinishReason{"eos": gai.ChatCompleteFinishReasonStop},
+		},
+	},
+	ProviderVLLM: {
+		baseURL:       "http://localhost:8000/v1/",
+		compatibility: Compatibility{NoDimensions: true},
+	},
+}
+
+// mapFinishReason using the compatibility finish reasons first, and then the standard ones.
+func (c Compatibility) mapFinishReason(reason string) gai.ChatCompleteFinishReason {
+	if mapped, ok := c.FinishReasons[reason]; ok {
+		return mapped
+	}
+	return mapChatFinishReason(reason)
+}
@@ -0,0 +1,107 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_Provider(t *testing.T) {
+	tests := []struct {
+		provider             openai.Provider
+		streamOptions        bool
+		responseFormat       string
+		dimensions           bool
+		finishReason         string
+		expectedFinishReason gai.ChatCompleteFinishReason
+	}{
+		{openai.ProviderGroq, true, "json_object", true, "stop", gai.ChatCompleteFinishReasonStop},
+		{openai.ProviderLlamaCPP, true, "json_schema", false, "stop", gai.ChatCompleteFinishReasonStop},
+		{openai.ProviderLMStudio, false, "json_schema", false, "stop", gai.ChatCompleteFinishReasonStop},
+		{openai.ProviderOllama, true, "json_schema", false, "length", gai.ChatCompleteFinishReasonLength},
+		{openai.ProviderOpenRouter, true, "json_schema", true, "stop", gai.ChatCompleteFinishReasonStop},
+		{openai.ProviderTogether, true, "json_schema", false, "eos", gai.ChatCompleteFinishReasonStop},
+		{openai.ProviderVLLM, true, "json_schema", false, "stop", gai.ChatCompleteFinishReasonStop},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.provider), func(t *testing.T) {
+			t.Setenv("OPENAI_API_KEY", "sk-openai")
+
+			bodies := map[string]map[string]any{}
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				is.Equal(t, "", r.Header.Get("Authorization"))
+
+				var body map[string]any
+				b, err := io.ReadAll(r.Body)
+				is.NotError(t, err)
+				is.NotError(t, json.Unmarshal(b, &body))
+				bodies[r.URL.Path] = body
+
+				if strings.HasSuffix(r.URL.Path, "/embeddings") {
+					writeEmbedding(w)
+					return
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"{}"}}]}` + "\n\n"))
+				_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"` + test.finishReason + `"}]}` + "\n\n"))
+				_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			}))
+			defer s.Close()
+
+			c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL + "/v1", Provider: test.provider})
+
+			cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: "m"})
+			res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+				Messages:       []gai.Message{gai.NewUserTextMessage("Hi!")},
+				ResponseSchema: &gai.Schema{Type: gai.SchemaTypeObject, Properties: map[string]*gai.Schema{"name": {Type: gai.SchemaTypeString}}},
+			})
+			is.NotError(t, err)
+			for _, err := range res.Parts() {
+				is.NotError(t, err)
+			}
+			is.Equal(t, test.expectedFinishReason, *res.Meta.FinishReason)
+
+			body := bodies["/v1/chat/completions"]
+			_, ok := body["stream_options"]
+			is.Equal(t, test.streamOptions, ok)
+			is.Equal(t, test.responseFormat, body["response_format"].(map[string]any)["type"].(string))
+
+			e := c.NewEmbedder(openai.NewEmbedderOptions{Model: "e", Dimensions: 3})
+			_, err = e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+			is.NotError(t, err)
+
+			_, ok = bodies["/v1/embeddings"]["dimensions"]
+			is.Equal(t, test.dimensions, ok)
+		})
+	}
+
+	t.Run("can override the compatibility preset", func(t *testing.T) {
+		var body map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.NotError(t, json.NewDecoder(r.Body).Decode(&body))
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:       s.URL,
+			Provider:      openai.ProviderOllama,
+			Compatibility: &openai.Compatibility{},
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: "e", Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, float64(3), body["dimensions"].(float64))
+	})
+}
@@ -0,0 +1,185 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"maragu.dev/errors"
+)
+
+// RateLimitOptions for limiting requests and tokens per minute on the client side,
+// shared by all chat completers and embedders created from the same [Client].
+// Each retry of a request, see [RetryOptions], is charged like a new request.
+type RateLimitOptions struct {
+	// RequestsPerMinute is the request budget. There is no limit if zero.
+	RequestsPerMinute int
+	// TokensPerMinute is the token budget. There is no limit if zero.
+	// Tokens are estimated before each request, and reconciled with the actual usage afterwards.
+	TokensPerMinute int
+	// FailFast returns [ErrRateLimited] when a budget is exhausted, instead of waiting for it to refill.
+	FailFast bool
+}
+
+// ErrRateLimited is returned when a client-side rate limit budget is exhausted and [RateLimitOptions.FailFast] is set.
+var ErrRateLimited = errors.New("client-side rate limit exceeded")
+
+// rateLimiter is a token bucket limiter for requests and tokens. A nil rateLimiter doesn't limit anything.
+type rateLimiter struct {
+	failFast bool
+	lock     sync.Mutex
+	now      func() time.Time
+	requests *bucket
+	tokens   *bucket
+}
+
+func newRateLimiter(opts RateLimitOptions) *rateLimiter {
+	if opts.RequestsPerMinute < 0 || opts.TokensPerMinute < 0 {
+		panic("rate limits must not be negative")
+	}
+
+	if opts.RequestsPerMinute == 0 && opts.TokensPerMinute == 0 {
+		return nil
+	}
+
+	l := &rateLimiter{
+		failFast: opts.FailFast,
+		now:      time.Now,
+	}
+	if opts.RequestsPerMinute > 0 {
+		l.requests = newBucket(opts.RequestsPerMinute, l.now())
+	}
+	if opts.TokensPerMinute > 0 {
+		l.tokens = newBucket(opts.TokensPerMinute, l.now())
+	}
+	return l
+}
+
+// wait until there's budget for one request with the given estimated tokens, and take it.
+// Estimates larger than the whole token budget are capped to it, so they can eventually go through.
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+
+	for {
+		l.lock.Lock()
+		now := l.now()
+		delay := max(l.requests.delay(1, now), l.tokens.delay(float64(tokens), now))
+		if delay == 0 {
+			l.requests.take(1)
+			l.tokens.take(float64(tokens))
+			l.lock.Unlock()
+			return nil
+		}
+		l.lock.Unlock()
+
+		if l.failFast {
+			return ErrRateLimited
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reconcile the estimated tokens taken in [rateLimiter.wait] with the actual usage.
+func (l *rateLimiter) reconcile(estimated, actual int) {
+	if l == nil || l.tokens == nil || actual <= 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.tokens.refill(l.now())
+	l.tokens.take(float64(min(actual, int(l.tokens.capacity)) - min(estimated, int(l.tokens.capacity))))
+}
+
+type rateLimitContextKey struct{}
+
+// rateLimited is a request that waited for the rate limiter, so retries of it are charged too.
+type rateLimited struct {
+	limiter *rateLimiter
+	tokens  int
+}
+
+// withRateLimit returns a context that makes [retrier.middleware] charge each retry of the request
+// to the rate limiter, with the same estimated tokens as the first attempt.
+func withRateLimit(ctx context.Context, l *rateLimiter, tokens int) context.Context {
+	if l == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rateLimitContextKey{}, rateLimited{limiter: l, tokens: tokens})
+}
+
+// waitForRetry waits for budget for a retry, if the request was rate limited with [withRateLimit].
+func waitForRetry(ctx context.Context) error {
+	r, ok := ctx.Value(rateLimitContextKey{}).(rateLimited)
+	if !ok {
+		return nil
+	}
+	return r.limiter.wait(ctx, r.tokens)
+}
+
+// bucket of budget that refills continuously up to its capacity over a minute.
+// A nil bucket always has budget.
+type bucket struct {
+	available float64
+	capacity  float64
+	last      time.Time
+	perSecond float64
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{
+		available: float64(perMinute),
+		capacity:  float64(perMinute),
+		last:      now,
+		perSecond: float64(perMinute) / 60,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.available = math.Min(b.capacity, b.available+now.Sub(b.last).Seconds()*b.perSecond)
+	b.last = now
+}
+
+// delay until n is available, after refilling. Zero means it's available now.
+func (b *bucket) delay(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.refill(now)
+	n = math.Min(n, b.capacity)
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.perSecond * float64(time.Second))
+}
+
+// take n from the bucket. The available budget can go negative if more was used than estimated.
+// A negative n is a refund, which never fills the bucket above its capacity.
+func (b *bucket) take(n float64) {
+	if b == nil {
+		return
+	}
+	b.available = math.Min(b.capacity, b.available-math.Min(n, b.capacity))
+}
+
+// estimateTokens in a request body, using the rough rule of thumb of four characters per token.
+// JSON syntax is counted too, so it slightly overestimates.
+func estimateTokens(body any) int {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 1
+	}
+	return estimateTokensFromChars(len(b))
+}
+
+func estimateTokensFromChars(chars int) int {
+	return chars/4 + 1
+}
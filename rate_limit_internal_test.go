@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("doesn't refund an overestimate above the token budget", func(t *testing.T) {
+		l, clock := newTestRateLimiter(RateLimitOptions{TokensPerMinute: 6000, FailFast: true})
+
+		is.NotError(t, l.wait(t.Context(), 50))
+
+		// The bucket is full again when the request finishes, and the overestimate is refunded on top of it
+		*clock = clock.Add(time.Minute)
+		l.reconcile(50, 1)
+		is.Equal(t, 6000.0, l.tokens.available)
+
+		// Uses the whole budget, so nothing is left for the next request
+		is.NotError(t, l.wait(t.Context(), 6000))
+		is.True(t, errors.Is(l.wait(t.Context(), 1), ErrRateLimited), "should be rate limited")
+	})
+
+	t.Run("charges an underestimate after the request", func(t *testing.T) {
+		l, clock := newTestRateLimiter(RateLimitOptions{TokensPerMinute: 6000, FailFast: true})
+
+		is.NotError(t, l.wait(t.Context(), 100))
+		l.reconcile(100, 6000)
+		is.Equal(t, 0.0, l.tokens.available)
+
+		// Refills at 100 tokens per second
+		*clock = clock.Add(time.Second)
+		is.True(t, errors.Is(l.wait(t.Context(), 200), ErrRateLimited), "should be rate limited")
+		*clock = clock.Add(time.Second)
+		is.NotError(t, l.wait(t.Context(), 200))
+	})
+}
+
+// newTestRateLimiter with a clock that only moves when the returned time is changed.
+func newTestRateLimiter(opts RateLimitOptions) (*rateLimiter, *time.Time) {
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newRateLimiter(opts)
+	l.now = func() time.Time { return clock }
+	for _, b := range []*bucket{l.requests, l.tokens} {
+		if b != nil {
+			b.last = clock
+		}
+	}
+	return l, &clock
+}
@@ -0,0 +1,124 @@
+package openai_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_RateLimit(t *testing.T) {
+	t.Run("fails fast when the request budget is exhausted", func(t *testing.T) {
+		c := newRateLimitedClient(t, openai.RateLimitOptions{RequestsPerMinute: 1, FailFast: true})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		_, err = e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, errors.Is(err, openai.ErrRateLimited), "should be rate limited")
+	})
+
+	t.Run("waits for the request budget to refill", func(t *testing.T) {
+		c := newRateLimitedClient(t, openai.RateLimitOptions{RequestsPerMinute: 1})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err = e.Embed(ctx, gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, errors.Is(err, context.DeadlineExceeded), "should have waited until the deadline")
+	})
+
+	t.Run("shares the budget between chat completers and embedders", func(t *testing.T) {
+		c := newRateLimitedClient(t, openai.RateLimitOptions{RequestsPerMinute: 1, FailFast: true})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		_, err = cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.True(t, errors.Is(err, openai.ErrRateLimited), "should be rate limited")
+	})
+
+	t.Run("reconciles estimated tokens with actual usage", func(t *testing.T) {
+		// The server reports 1 token used, so the overestimate is refunded
+		c := newRateLimitedClient(t, openai.RateLimitOptions{TokensPerMinute: 100, FailFast: true})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		for range 3 {
+			_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader(strings.Repeat("a", 200))})
+			is.NotError(t, err)
+		}
+	})
+
+	t.Run("charges retries to the budget", func(t *testing.T) {
+		var requests int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test",
+			RateLimit: openai.RateLimitOptions{RequestsPerMinute: 1, FailFast: true},
+			Retry:     openai.RetryOptions{InitialBackoff: time.Millisecond}})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, errors.Is(err, openai.ErrRateLimited), "should be rate limited")
+		is.Equal(t, 1, requests)
+	})
+
+	t.Run("charges chat completion stream retries to the budget", func(t *testing.T) {
+		var requests int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error"}}` + "\n\n"))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test",
+			RateLimit: openai.RateLimitOptions{RequestsPerMinute: 1, FailFast: true},
+			Retry:     openai.RetryOptions{InitialBackoff: time.Millisecond}})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var partErr error
+		for _, err := range res.Parts() {
+			partErr = err
+		}
+		is.True(t, errors.Is(partErr, openai.ErrRateLimited), "should be rate limited")
+		is.Equal(t, 1, requests)
+	})
+}
+
+func newRateLimitedClient(t *testing.T, opts openai.RateLimitOptions) *openai.Client {
+	t.Helper()
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEmbedding(w)
+	}))
+	t.Cleanup(s.Close)
+
+	return openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", RateLimit: opts})
+}
@@ -0,0 +1,244 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"maragu.dev/errors"
+)
+
+// RetryOptions for retrying failed requests with exponential backoff.
+// Requests are retried on connection errors and on status codes 408, 409, 429, and 5xx,
+// unless the response has the header "x-should-retry: false".
+type RetryOptions struct {
+	// MaxRetries after the first attempt. Defaults to 2. Set to a negative number to disable retries.
+	MaxRetries int
+	// InitialBackoff before the first retry. It's doubled for every retry. Defaults to 500ms.
+	InitialBackoff time.Duration
+	// MaxBackoff between retries. Defaults to 8s.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the backoff, between 0 and 1, that is randomly subtracted from it,
+	// so that many clients don't retry at the same time. Defaults to 0.25. Set to a negative number to disable jitter.
+	Jitter float64
+	// IgnoreRetryAfter disables waiting for the duration given by the Retry-After, retry-after-ms,
+	// and x-ratelimit-reset-* response headers, and always uses the backoff instead.
+	IgnoreRetryAfter bool
+	// MaxRetryAfter is the longest wait from response headers to respect. If the server asks for a longer wait,
+	// the request is not retried. Defaults to 60s.
+	MaxRetryAfter time.Duration
+}
+
+// retrier retries requests according to [RetryOptions].
+type retrier struct {
+	ignoreRetryAfter bool
+	initialBackoff   time.Duration
+	jitter           float64
+	log              *slog.Logger
+	maxBackoff       time.Duration
+	maxRetries       int
+	maxRetryAfter    time.Duration
+}
+
+func newRetrier(opts RetryOptions, log *slog.Logger) *retrier {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 2
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 8 * time.Second
+	}
+	if opts.Jitter == 0 {
+		opts.Jitter = 0.25
+	}
+	if opts.Jitter < 0 {
+		opts.Jitter = 0
+	}
+	if opts.Jitter > 1 {
+		panic("jitter must be between 0 and 1")
+	}
+	if opts.MaxRetryAfter <= 0 {
+		opts.MaxRetryAfter = 60 * time.Second
+	}
+
+	return &retrier{
+		ignoreRetryAfter: opts.IgnoreRetryAfter,
+		initialBackoff:   opts.InitialBackoff,
+		jitter:           opts.Jitter,
+		log:              log,
+		maxBackoff:       opts.MaxBackoff,
+		maxRetries:       opts.MaxRetries,
+		maxRetryAfter:    opts.MaxRetryAfter,
+	}
+}
+
+// middleware retries HTTP requests. It replaces the retry logic of the OpenAI SDK, which must be disabled.
+func (r *retrier) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := next(req)
+
+		if attempt >= r.maxRetries || !shouldRetry(req, res, err) {
+			return res, err
+		}
+
+		delay, ok := r.delay(attempt, res)
+		if !ok {
+			return res, err
+		}
+
+		if res != nil {
+			// Drain and close the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		r.log.Info("Retrying request", "method", req.Method, "url", req.URL.String(), "status", status,
+			"requestID", requestIDOf(res), "error", err, "attempt", attempt+1, "delay", delay)
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+
+		if err := waitForRetry(req.Context()); err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting request body for retry")
+			}
+			req.Body = body
+		}
+	}
+}
+
+// delay before the next retry. If the server asks for a wait longer than the maximum, it returns false.
+func (r *retrier) delay(attempt int, res *http.Response) (time.Duration, bool) {
+	if !r.ignoreRetryAfter {
+		if d, ok := parseRetryAfter(res); ok {
+			if d > r.maxRetryAfter {
+				return 0, false
+			}
+			return d, true
+		}
+	}
+
+	return r.backoff(attempt), true
+}
+
+// backoff for the given zero-indexed retry attempt, with jitter.
+func (r *retrier) backoff(attempt int) time.Duration {
+	d := float64(r.initialBackoff) * math.Pow(2, float64(attempt))
+	d = math.Min(d, float64(r.maxBackoff))
+	d -= d * r.jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+// parseRetryAfter from the response headers.
+// The retry-after-ms and Retry-After headers are tried first. Then, if a rate limit is exhausted,
+// the corresponding x-ratelimit-reset-requests or x-ratelimit-reset-tokens header is used.
+func parseRetryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+
+	if v := res.Header.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	if v := res.Header.Get("retry-after"); v != "" {
+		if s, err := strconv.ParseFloat(v, 64); err == nil && s >= 0 {
+			return time.Duration(s * float64(time.Second)), true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(time.Until(t), 0), true
+		}
+	}
+
+	var d time.Duration
+	var found bool
+	for _, kind := range []string{"requests", "tokens"} {
+		if res.Header.Get("x-ratelimit-remaining-"+kind) != "0" {
+			continue
+		}
+		if reset, err := time.ParseDuration(res.Header.Get("x-ratelimit-reset-" + kind)); err == nil {
+			d = max(d, reset)
+			found = true
+		}
+	}
+	return d, found
+}
+
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	// If there is no way to recover the body, we can't retry
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	// Connection errors
+	if res == nil {
+		return err != nil
+	}
+
+	switch res.Header.Get("x-should-retry") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	return res.StatusCode == http.StatusRequestTimeout ||
+		res.StatusCode == http.StatusConflict ||
+		res.StatusCode == http.StatusTooManyRequests ||
+		res.StatusCode >= http.StatusInternalServerError
+}
+
+// isRetryableStreamError is true for errors that happen while reading a stream, such as dropped connections
+// and error events. HTTP status and connection errors are not retryable here,
+// because they have already been retried by [retrier.middleware].
+func isRetryableStreamError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *openai.Error
+	var urlErr *url.Error
+	return !errors.As(err, &apiErr) && !errors.As(err, &urlErr)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package openai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_Retry(t *testing.T) {
+	t.Run("retries rate limited requests using the retry-after-ms header", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.Header().Set("retry-after-ms", "10")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		e := newRetryEmbedder(s.URL, openai.RetryOptions{InitialBackoff: time.Hour})
+
+		start := time.Now()
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, int32(3), attempts.Load())
+		is.True(t, time.Since(start) < time.Second, "should have used retry-after-ms instead of the backoff")
+	})
+
+	t.Run("waits for the exhausted rate limit to reset", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 2 {
+				w.Header().Set("x-ratelimit-remaining-requests", "10")
+				w.Header().Set("x-ratelimit-reset-requests", "1h")
+				w.Header().Set("x-ratelimit-remaining-tokens", "0")
+				w.Header().Set("x-ratelimit-reset-tokens", "20ms")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		e := newRetryEmbedder(s.URL, openai.RetryOptions{InitialBackoff: time.Hour})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+		is.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer s.Close()
+
+		e := newRetryEmbedder(s.URL, openai.RetryOptions{MaxRetries: 3, InitialBackoff: time.Millisecond})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, err != nil, "should error")
+		is.Equal(t, int32(4), attempts.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer s.Close()
+
+		e := newRetryEmbedder(s.URL, openai.RetryOptions{InitialBackoff: time.Millisecond})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, err != nil, "should error")
+		is.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("does not retry when disabled", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer s.Close()
+
+		e := newRetryEmbedder(s.URL, openai.RetryOptions{MaxRetries: -1})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.True(t, err != nil, "should error")
+		is.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("retries chat completion streams that fail before anything is yielded", func(t *testing.T) {
+		var attempts atomic.Int32
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			if attempts.Add(1) == 1 {
+				_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}` + "\n\n"))
+				_, _ = w.Write([]byte(`data: {"error":{"message":"The server had an error while processing your request.","type":"server_error"}}` + "\n\n"))
+				return
+			}
+			_, _ = w.Write([]byte(`data: {"id":"2","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"}}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"2","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test", Retry: openai.RetryOptions{InitialBackoff: time.Millisecond}})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+
+		var output string
+		for part, err := range res.Parts() {
+			is.NotError(t, err)
+			output += part.Text()
+		}
+		is.Equal(t, "Hello!", output)
+		is.Equal(t, int32(2), attempts.Load())
+		is.Equal(t, gai.ChatCompleteFinishReasonStop, *res.Meta.FinishReason)
+	})
+}
+
+func newRetryEmbedder(baseURL string, opts openai.RetryOptions) *openai.Embedder {
+	c := openai.NewClient(openai.NewClientOptions{BaseURL: baseURL, Key: "test", Retry: opts})
+	return c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+}
+
+func writeEmbedding(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,0.2,0.3]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":1,"total_tokens":1}}`))
+}
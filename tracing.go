@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes from the OpenTelemetry semantic conventions for generative AI.
+// See https://opentelemetry.io/docs/specs/semconv/gen-ai/gen-ai-spans/
+const (
+	genAIEmbeddingsDimensionCount = attribute.Key("gen_ai.embeddings.dimension.count")
+	genAIOperationName            = attribute.Key("gen_ai.operation.name")
+	genAIProviderName             = attribute.Key("gen_ai.provider.name")
+	genAIRequestEncodingFormats   = attribute.Key("gen_ai.request.encoding_formats")
+	genAIRequestModel             = attribute.Key("gen_ai.request.model")
+	genAIRequestTemperature       = attribute.Key("gen_ai.request.temperature")
+	genAIResponseFinishReasons    = attribute.Key("gen_ai.response.finish_reasons")
+	genAIResponseID               = attribute.Key("gen_ai.response.id")
+	genAIResponseModel            = attribute.Key("gen_ai.response.model")
+	genAISystem                   = attribute.Key("gen_ai.system")
+	genAIUsageInputTokens         = attribute.Key("gen_ai.usage.input_tokens")
+	genAIUsageOutputTokens        = attribute.Key("gen_ai.usage.output_tokens")
+	errorTypeKey                  = attribute.Key("error.type")
+)
+
+// Span attributes for token usage details, cost, and OpenAI request options, in the style of the semantic conventions.
+const (
+	genAIOpenAIRequestPromptCacheKey         = attribute.Key("gen_ai.openai.request.prompt_cache_key")
+	genAIUsageAcceptedPredictionOutputTokens = attribute.Key("gen_ai.usage.accepted_prediction.output_tokens")
+	genAIUsageAudioInputTokens               = attribute.Key("gen_ai.usage.audio.input_tokens")
+	genAIUsageAudioOutputTokens              = attribute.Key("gen_ai.usage.audio.output_tokens")
+	genAIUsageCacheReadInputTokens           = attribute.Key("gen_ai.usage.cache_read.input_tokens")
+	genAIUsageEstimatedCostUSD               = attribute.Key("gen_ai.usage.estimated_cost_usd")
+	genAIUsageReasoningOutputTokens          = attribute.Key("gen_ai.usage.reasoning.output_tokens")
+	genAIUsageRejectedPredictionOutputTokens = attribute.Key("gen_ai.usage.rejected_prediction.output_tokens")
+)
+
+func newTracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer("maragu.dev/gai-openai")
+}
+
+// genAISystemName for the gen_ai.system and gen_ai.provider.name attributes.
+func genAISystemName(opts NewClientOptions) string {
+	switch {
+	case opts.Azure != nil:
+		return "azure.ai.openai"
+	case opts.Provider == ProviderOpenAI:
+		return "openai"
+	default:
+		return string(opts.Provider)
+	}
+}
+
+// genAIAttributes common to all gen_ai spans.
+func genAIAttributes(operation, system, model string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		genAIOperationName.String(operation),
+		genAIProviderName.String(system),
+		genAISystem.String(system),
+		genAIRequestModel.String(model),
+	}
+}
@@ -0,0 +1,132 @@
+package openai_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestNewClient_Tracing(t *testing.T) {
+	t.Run("records chat completion spans with gen_ai semantic conventions", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"}}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini-2024-07-18","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini-2024-07-18","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:        s.URL,
+			Key:            "test",
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		res, err := cc.ChatComplete(t.Context(), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		spans := recorder.Ended()
+		is.Equal(t, 1, len(spans))
+		is.Equal(t, "chat gpt-4o-mini", spans[0].Name())
+
+		attrs := attributesOf(spans[0])
+		is.Equal(t, "chat", attrs["gen_ai.operation.name"].AsString())
+		is.Equal(t, "openai", attrs["gen_ai.provider.name"].AsString())
+		is.Equal(t, "openai", attrs["gen_ai.system"].AsString())
+		is.Equal(t, "gpt-4o-mini", attrs["gen_ai.request.model"].AsString())
+		is.Equal(t, "gpt-4o-mini-2024-07-18", attrs["gen_ai.response.model"].AsString())
+		is.Equal(t, "chatcmpl-1", attrs["gen_ai.response.id"].AsString())
+		is.Equal(t, int64(10), attrs["gen_ai.usage.input_tokens"].AsInt64())
+		is.Equal(t, int64(2), attrs["gen_ai.usage.output_tokens"].AsInt64())
+		is.Equal(t, "stop", attrs["gen_ai.response.finish_reasons"].AsStringSlice()[0])
+
+		_, ok := attrs["ai.model"]
+		is.True(t, !ok, "should not have legacy attributes")
+	})
+
+	t.Run("records embedding spans with legacy attributes", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeEmbedding(w)
+		}))
+		defer s.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:              s.URL,
+			Key:                  "test",
+			LegacySpanAttributes: true,
+			TracerProvider:       sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		_, err := e.Embed(t.Context(), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		spans := recorder.Ended()
+		is.Equal(t, 1, len(spans))
+		is.Equal(t, "embeddings text-embedding-3-small", spans[0].Name())
+
+		attrs := attributesOf(spans[0])
+		is.Equal(t, "embeddings", attrs["gen_ai.operation.name"].AsString())
+		is.Equal(t, int64(3), attrs["gen_ai.embeddings.dimension.count"].AsInt64())
+		is.Equal(t, int64(1), attrs["gen_ai.usage.input_tokens"].AsInt64())
+		is.Equal(t, "text-embedding-3-small", attrs["ai.model"].AsString())
+		is.Equal(t, int64(1), attrs["ai.prompt_tokens"].AsInt64())
+	})
+
+	t.Run("records moderation spans without legacy attributes by default", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":false,"categories":{},"category_scores":{},"category_applied_input_types":{}}]}`))
+		}))
+		defer s.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:        s.URL,
+			Key:            "test",
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		m := c.NewModerator(openai.NewModeratorOptions{Model: openai.ModerateModelOmniModerationLatest})
+
+		_, err := m.Moderate(t.Context(), openai.ModerateRequest{Text: "Hi!"})
+		is.NotError(t, err)
+
+		spans := recorder.Ended()
+		is.Equal(t, 1, len(spans))
+		is.Equal(t, "moderation omni-moderation-latest", spans[0].Name())
+
+		attrs := attributesOf(spans[0])
+		is.Equal(t, "moderation", attrs["gen_ai.operation.name"].AsString())
+		is.Equal(t, "openai", attrs["gen_ai.provider.name"].AsString())
+		is.Equal(t, "omni-moderation-latest", attrs["gen_ai.request.model"].AsString())
+
+		_, ok := attrs["ai.model"]
+		is.True(t, !ok, "should not have legacy attributes")
+	})
+}
+
+func attributesOf(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
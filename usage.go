@@ -0,0 +1,78 @@
+package openai
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// UsageAccumulator aggregates the [Usage] of chat completions and embeddings per tenant.
+// The tenant of a request is set with [WithTenant]. Requests without a tenant are aggregated under the empty string.
+// It's safe for concurrent use.
+type UsageAccumulator struct {
+	lock  sync.Mutex
+	usage map[string]Usage
+}
+
+func NewUsageAccumulator() *UsageAccumulator {
+	return &UsageAccumulator{usage: map[string]Usage{}}
+}
+
+// Add usage for the tenant.
+func (a *UsageAccumulator) Add(tenant string, u Usage) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.usage[tenant] = a.usage[tenant].Add(u)
+}
+
+// Usage of the tenant so far.
+func (a *UsageAccumulator) Usage(tenant string) Usage {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.usage[tenant]
+}
+
+// Tenants returns a copy of the usage of all tenants so far.
+func (a *UsageAccumulator) Tenants() map[string]Usage {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return maps.Clone(a.usage)
+}
+
+// record usage for the tenant in the context. It does nothing if a is nil.
+func (a *UsageAccumulator) record(ctx context.Context, u Usage) {
+	if a == nil {
+		return
+	}
+	a.Add(tenantFromContext(ctx), u)
+}
+
+type usageIntoContextKey struct{}
+
+// WithUsageInto returns a context that makes requests using it, such as [ChatCompleter.ChatComplete]
+// and [Embedder.Embed], copy their [Usage] into u, including cached prompt tokens and reasoning tokens,
+// which [gai.ChatCompleteResponseUsage] doesn't have.
+// For chat completions, u is set when the response has been read.
+func WithUsageInto(ctx context.Context, u *Usage) context.Context {
+	return context.WithValue(ctx, usageIntoContextKey{}, u)
+}
+
+// storeUsageInto the usage from [WithUsageInto] in the context, if any.
+func storeUsageInto(ctx context.Context, u Usage) {
+	if dst, ok := ctx.Value(usageIntoContextKey{}).(*Usage); ok && dst != nil {
+		*dst = u
+	}
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context that attributes the usage of requests using it to the given tenant,
+// for example a customer or user ID.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
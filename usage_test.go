@@ -0,0 +1,124 @@
+package openai_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestUsageAccumulator(t *testing.T) {
+	t.Run("aggregates usage and cost of chat completions and embeddings per tenant", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/embeddings") {
+				writeEmbedding(w)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,"prompt_tokens_details":{"cached_tokens":400},"completion_tokens_details":{"reasoning_tokens":0}}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		acc := openai.NewUsageAccumulator()
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:          s.URL,
+			Key:              "test",
+			TracerProvider:   sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+			UsageAccumulator: acc,
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+		e := c.NewEmbedder(openai.NewEmbedderOptions{Model: openai.EmbedModelTextEmbedding3Small, Dimensions: 3})
+
+		for _, ctx := range []context.Context{openai.WithTenant(t.Context(), "a"), openai.WithTenant(t.Context(), "a"), t.Context()} {
+			res, err := cc.ChatComplete(ctx, gai.ChatCompleteRequest{
+				Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+			})
+			is.NotError(t, err)
+			for _, err := range res.Parts() {
+				is.NotError(t, err)
+			}
+		}
+
+		_, err := e.Embed(openai.WithTenant(t.Context(), "b"), gai.EmbedRequest{Input: strings.NewReader("Hi!")})
+		is.NotError(t, err)
+
+		chatCost := (600*0.15 + 400*0.075 + 100*0.60) / 1_000_000
+
+		a := acc.Usage("a")
+		is.Equal(t, 2, a.Requests)
+		is.Equal(t, 2000, a.PromptTokens)
+		is.Equal(t, 800, a.CachedPromptTokens)
+		is.Equal(t, 200, a.CompletionTokens)
+		is.True(t, a.Cost > 2*chatCost*0.999 && a.Cost < 2*chatCost*1.001, "should have cost of two chat completions")
+
+		b := acc.Usage("b")
+		is.Equal(t, 1, b.Requests)
+		is.Equal(t, 1, b.PromptTokens)
+		is.Equal(t, 0.02/1_000_000, b.Cost)
+
+		is.Equal(t, 1, acc.Usage("").Requests)
+		is.Equal(t, 3, len(acc.Tenants()))
+
+		spans := recorder.Ended()
+		is.Equal(t, 4, len(spans))
+		is.Equal(t, chatCost, attributesOf(spans[0])["gen_ai.usage.estimated_cost_usd"].AsFloat64())
+	})
+}
+
+func TestWithUsageInto(t *testing.T) {
+	t.Run("copies detailed usage of a chat completion and records it on the span", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}]}` + "\n\n"))
+			_, _ = w.Write([]byte(`data: {"id":"1","object":"chat.completion.chunk","created":0,"model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":1000,"completion_tokens":100,"total_tokens":1100,"prompt_tokens_details":{"cached_tokens":400,"audio_tokens":1},"completion_tokens_details":{"reasoning_tokens":60,"audio_tokens":2,"accepted_prediction_tokens":3,"rejected_prediction_tokens":4}}}` + "\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}))
+		defer s.Close()
+
+		recorder := tracetest.NewSpanRecorder()
+		c := openai.NewClient(openai.NewClientOptions{
+			BaseURL:        s.URL,
+			Key:            "test",
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		})
+		cc := c.NewChatCompleter(openai.NewChatCompleterOptions{Model: openai.ChatCompleteModelGPT4oMini})
+
+		var usage openai.Usage
+		res, err := cc.ChatComplete(openai.WithUsageInto(t.Context(), &usage), gai.ChatCompleteRequest{
+			Messages: []gai.Message{gai.NewUserTextMessage("Hi!")},
+		})
+		is.NotError(t, err)
+		for _, err := range res.Parts() {
+			is.NotError(t, err)
+		}
+
+		is.Equal(t, 1, usage.Requests)
+		is.Equal(t, 1000, usage.PromptTokens)
+		is.Equal(t, 400, usage.CachedPromptTokens)
+		is.Equal(t, 100, usage.CompletionTokens)
+		is.Equal(t, 60, usage.ReasoningTokens)
+		is.Equal(t, 1, usage.AudioPromptTokens)
+		is.Equal(t, 2, usage.AudioCompletionTokens)
+		is.Equal(t, 3, usage.AcceptedPredictionTokens)
+		is.Equal(t, 4, usage.RejectedPredictionTokens)
+		is.True(t, usage.Cost > 0, "should have cost")
+
+		attrs := attributesOf(recorder.Ended()[0])
+		is.Equal(t, int64(400), attrs["gen_ai.usage.cache_read.input_tokens"].AsInt64())
+		is.Equal(t, int64(60), attrs["gen_ai.usage.reasoning.output_tokens"].AsInt64())
+		is.Equal(t, int64(4), attrs["gen_ai.usage.rejected_prediction.output_tokens"].AsInt64())
+		_, ok := attrs["ai.cached_prompt_tokens"]
+		is.True(t, !ok, "should not have ai.* attributes")
+	})
+}
@@ -0,0 +1,469 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"maragu.dev/errors"
+	"maragu.dev/gai"
+)
+
+type VectorStoreStatus string
+
+const (
+	VectorStoreStatusCompleted  = VectorStoreStatus(openai.VectorStoreStatusCompleted)
+	VectorStoreStatusExpired    = VectorStoreStatus(openai.VectorStoreStatusExpired)
+	VectorStoreStatusInProgress = VectorStoreStatus(openai.VectorStoreStatusInProgress)
+)
+
+// VectorStore is a hosted vector store of files that can be searched.
+type VectorStore struct {
+	ID        string
+	Name      string
+	Status    VectorStoreStatus
+	CreatedAt time.Time
+	// FileCounts by ingestion status.
+	FileCounts VectorStoreFileCounts
+}
+
+type VectorStoreFileCounts struct {
+	InProgress int
+	Completed  int
+	Failed     int
+	Cancelled  int
+	Total      int
+}
+
+type CreateVectorStoreOptions struct {
+	Name string
+	// FileIDs of already uploaded files to add to the store. See [Client.UploadFile].
+	FileIDs  []string
+	Metadata map[string]string
+	// ExpiresAfterDays of inactivity. The store doesn't expire if zero.
+	ExpiresAfterDays int
+}
+
+// CreateVectorStore with the given options. Files are ingested asynchronously, see [Client.WaitForVectorStore].
+func (c *Client) CreateVectorStore(ctx context.Context, opts CreateVectorStoreOptions) (VectorStore, error) {
+	params := openai.VectorStoreNewParams{
+		FileIDs:  opts.FileIDs,
+		Metadata: opts.Metadata,
+	}
+	if opts.Name != "" {
+		params.Name = openai.String(opts.Name)
+	}
+	if opts.ExpiresAfterDays > 0 {
+		params.ExpiresAfter = openai.VectorStoreNewParamsExpiresAfter{Days: int64(opts.ExpiresAfterDays)}
+	}
+
+	res, err := c.Client.VectorStores.New(ctx, params)
+	if err != nil {
+		return VectorStore{}, errors.Wrap(toAPIError(err), "error creating vector store")
+	}
+	return toVectorStore(res), nil
+}
+
+// GetVectorStore by ID.
+func (c *Client) GetVectorStore(ctx context.Context, id string) (VectorStore, error) {
+	res, err := c.Client.VectorStores.Get(ctx, id)
+	if err != nil {
+		return VectorStore{}, errors.Wrap(toAPIError(err), "error getting vector store")
+	}
+	return toVectorStore(res), nil
+}
+
+// DeleteVectorStore by ID. The files in the store are not deleted.
+func (c *Client) DeleteVectorStore(ctx context.Context, id string) error {
+	if _, err := c.Client.VectorStores.Delete(ctx, id); err != nil {
+		return errors.Wrap(toAPIError(err), "error deleting vector store")
+	}
+	return nil
+}
+
+type AddVectorStoreFileOptions struct {
+	// FileID of an already uploaded file. See [Client.UploadFile].
+	FileID string
+	// Attributes to filter on when searching, see [VectorStoreFilter].
+	// Values must be strings, bools, or numbers.
+	Attributes map[string]any
+}
+
+// AddVectorStoreFile adds an uploaded file to a vector store. The file is ingested asynchronously, see [Client.WaitForVectorStore].
+func (c *Client) AddVectorStoreFile(ctx context.Context, vectorStoreID string, opts AddVectorStoreFileOptions) error {
+	params := openai.VectorStoreFileNewParams{
+		FileID: opts.FileID,
+	}
+
+	if len(opts.Attributes) > 0 {
+		params.Attributes = map[string]openai.VectorStoreFileNewParamsAttributeUnion{}
+		for k, v := range opts.Attributes {
+			value, err := toFilterValue(v)
+			if err != nil {
+				return errors.Wrap(err, "invalid attribute %v", k)
+			}
+			params.Attributes[k] = openai.VectorStoreFileNewParamsAttributeUnion{
+				OfString: value.OfString,
+				OfFloat:  value.OfFloat,
+				OfBool:   value.OfBool,
+			}
+		}
+	}
+
+	if _, err := c.Client.VectorStores.Files.New(ctx, vectorStoreID, params); err != nil {
+		return errors.Wrap(toAPIError(err), "error adding file to vector store")
+	}
+	return nil
+}
+
+type WaitForVectorStoreOptions struct {
+	// PollInterval for [Client.WaitForVectorStore]. Defaults to 1 second.
+	PollInterval time.Duration
+}
+
+// WaitForVectorStore polls the vector store until no files are being ingested, or the context is cancelled.
+// Files that failed ingestion are counted in [VectorStoreFileCounts], they don't cause an error.
+func (c *Client) WaitForVectorStore(ctx context.Context, id string, opts WaitForVectorStoreOptions) (VectorStore, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		store, err := c.GetVectorStore(ctx, id)
+		if err != nil {
+			return VectorStore{}, err
+		}
+		if store.Status != VectorStoreStatusInProgress && store.FileCounts.InProgress == 0 {
+			return store, nil
+		}
+
+		c.log.Debug("Waiting for vector store", "id", id, "inProgress", store.FileCounts.InProgress, "total", store.FileCounts.Total)
+
+		select {
+		case <-ctx.Done():
+			return store, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func toVectorStore(s *openai.VectorStore) VectorStore {
+	return VectorStore{
+		ID:        s.ID,
+		Name:      s.Name,
+		Status:    VectorStoreStatus(s.Status),
+		CreatedAt: time.Unix(s.CreatedAt, 0).UTC(),
+		FileCounts: VectorStoreFileCounts{
+			InProgress: int(s.FileCounts.InProgress),
+			Completed:  int(s.FileCounts.Completed),
+			Failed:     int(s.FileCounts.Failed),
+			Cancelled:  int(s.FileCounts.Cancelled),
+			Total:      int(s.FileCounts.Total),
+		},
+	}
+}
+
+// VectorStoreFilter filters search results by file attributes.
+// Create one with the comparison functions like [FilterEq], and combine them with [FilterAnd] and [FilterOr].
+type VectorStoreFilter struct {
+	compound bool
+	filters  []VectorStoreFilter
+	key      string
+	op       string
+	value    any
+}
+
+// FilterEq matches files where the attribute key equals value.
+func FilterEq(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeEq), value: value}
+}
+
+// FilterNe matches files where the attribute key doesn't equal value.
+func FilterNe(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeNe), value: value}
+}
+
+// FilterGt matches files where the attribute key is greater than value.
+func FilterGt(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeGt), value: value}
+}
+
+// FilterGte matches files where the attribute key is greater than or equal to value.
+func FilterGte(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeGte), value: value}
+}
+
+// FilterLt matches files where the attribute key is less than value.
+func FilterLt(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeLt), value: value}
+}
+
+// FilterLte matches files where the attribute key is less than or equal to value.
+func FilterLte(key string, value any) VectorStoreFilter {
+	return VectorStoreFilter{key: key, op: string(shared.ComparisonFilterTypeLte), value: value}
+}
+
+// FilterAnd matches files that match all filters. The filters must be comparisons, not nested [FilterAnd] or [FilterOr].
+func FilterAnd(filters ...VectorStoreFilter) VectorStoreFilter {
+	return VectorStoreFilter{compound: true, op: string(shared.CompoundFilterTypeAnd), filters: filters}
+}
+
+// FilterOr matches files that match any of the filters. The filters must be comparisons, not nested [FilterAnd] or [FilterOr].
+func FilterOr(filters ...VectorStoreFilter) VectorStoreFilter {
+	return VectorStoreFilter{compound: true, op: string(shared.CompoundFilterTypeOr), filters: filters}
+}
+
+func (f VectorStoreFilter) toComparison() (*shared.ComparisonFilterParam, error) {
+	if f.compound {
+		return nil, errors.New("nested compound filters are not supported")
+	}
+
+	value, err := toFilterValue(f.value)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid filter value for %v", f.key)
+	}
+
+	return &shared.ComparisonFilterParam{
+		Key:   f.key,
+		Type:  shared.ComparisonFilterType(f.op),
+		Value: value,
+	}, nil
+}
+
+func (f VectorStoreFilter) toParam() (openai.VectorStoreSearchParamsFiltersUnion, error) {
+	if !f.compound {
+		comparison, err := f.toComparison()
+		if err != nil {
+			return openai.VectorStoreSearchParamsFiltersUnion{}, err
+		}
+		return openai.VectorStoreSearchParamsFiltersUnion{OfComparisonFilter: comparison}, nil
+	}
+
+	if len(f.filters) == 0 {
+		return openai.VectorStoreSearchParamsFiltersUnion{}, errors.Newf("compound %v filter must have at least one filter", f.op)
+	}
+
+	compound := &shared.CompoundFilterParam{Type: shared.CompoundFilterType(f.op)}
+	for _, child := range f.filters {
+		comparison, err := child.toComparison()
+		if err != nil {
+			return openai.VectorStoreSearchParamsFiltersUnion{}, err
+		}
+		compound.Filters = append(compound.Filters, *comparison)
+	}
+	return openai.VectorStoreSearchParamsFiltersUnion{OfCompoundFilter: compound}, nil
+}
+
+func toFilterValue(v any) (shared.ComparisonFilterValueUnionParam, error) {
+	switch v := v.(type) {
+	case string:
+		return shared.ComparisonFilterValueUnionParam{OfString: openai.String(v)}, nil
+	case bool:
+		return shared.ComparisonFilterValueUnionParam{OfBool: openai.Bool(v)}, nil
+	case int:
+		return shared.ComparisonFilterValueUnionParam{OfFloat: openai.Float(float64(v))}, nil
+	case int64:
+		return shared.ComparisonFilterValueUnionParam{OfFloat: openai.Float(float64(v))}, nil
+	case float32:
+		return shared.ComparisonFilterValueUnionParam{OfFloat: openai.Float(float64(v))}, nil
+	case float64:
+		return shared.ComparisonFilterValueUnionParam{OfFloat: openai.Float(v)}, nil
+	default:
+		return shared.ComparisonFilterValueUnionParam{}, errors.Newf("unsupported value type %T", v)
+	}
+}
+
+// VectorStoreSearcher searches a single vector store.
+type VectorStoreSearcher struct {
+	Client         openai.Client
+	log            *slog.Logger
+	maxResults     int
+	ranker         string
+	rewriteQuery   bool
+	scoreThreshold float64
+	tracer         trace.Tracer
+	vectorStoreID  string
+}
+
+type NewVectorStoreSearcherOptions struct {
+	VectorStoreID string
+	// MaxResults to return, between 1 and 50. Defaults to 10.
+	MaxResults int
+	// Ranker to use, such as "auto" or "default-2024-11-15". Uses the API default if empty.
+	Ranker string
+	// RewriteQuery for better vector search results.
+	RewriteQuery bool
+	// ScoreThreshold between 0 and 1. Results with a lower score are not returned.
+	ScoreThreshold float64
+}
+
+func (c *Client) NewVectorStoreSearcher(opts NewVectorStoreSearcherOptions) *VectorStoreSearcher {
+	if opts.VectorStoreID == "" {
+		panic("vector store ID must not be empty")
+	}
+
+	if opts.MaxResults == 0 {
+		opts.MaxResults = 10
+	}
+	if opts.MaxResults < 1 || opts.MaxResults > 50 {
+		panic("max results must be between 1 and 50")
+	}
+
+	if opts.ScoreThreshold < 0 || opts.ScoreThreshold > 1 {
+		panic("score threshold must be between 0 and 1")
+	}
+
+	return &VectorStoreSearcher{
+		Client:         c.Client,
+		log:            c.log,
+		maxResults:     opts.MaxResults,
+		ranker:         opts.Ranker,
+		rewriteQuery:   opts.RewriteQuery,
+		scoreThreshold: opts.ScoreThreshold,
+		tracer:         c.tracer,
+		vectorStoreID:  opts.VectorStoreID,
+	}
+}
+
+type VectorStoreSearchRequest struct {
+	Query string
+	// Filter is optional.
+	Filter *VectorStoreFilter
+}
+
+// VectorStoreSearchResult is a matching chunk of a file.
+type VectorStoreSearchResult struct {
+	FileID   string
+	Filename string
+	Score    float64
+	Text     string
+	// Attributes of the file, with values that are strings, bools, or float64s.
+	Attributes map[string]any
+}
+
+// Search the vector store. Results are ordered by descending score.
+func (s *VectorStoreSearcher) Search(ctx context.Context, req VectorStoreSearchRequest) ([]VectorStoreSearchResult, error) {
+	ctx, span := s.tracer.Start(ctx, "openai.vector_store_search",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("ai.vector_store_id", s.vectorStoreID),
+			attribute.Int("ai.max_results", s.maxResults),
+			attribute.Bool("ai.has_filter", req.Filter != nil),
+		),
+	)
+	defer span.End()
+
+	params := openai.VectorStoreSearchParams{
+		Query:         openai.VectorStoreSearchParamsQueryUnion{OfString: openai.String(req.Query)},
+		MaxNumResults: openai.Int(int64(s.maxResults)),
+		RewriteQuery:  openai.Bool(s.rewriteQuery),
+	}
+
+	if req.Filter != nil {
+		filter, err := req.Filter.toParam()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "invalid filter")
+			return nil, err
+		}
+		params.Filters = filter
+	}
+
+	if s.ranker != "" || s.scoreThreshold > 0 {
+		params.RankingOptions = openai.VectorStoreSearchParamsRankingOptions{Ranker: s.ranker}
+		if s.scoreThreshold > 0 {
+			params.RankingOptions.ScoreThreshold = openai.Float(s.scoreThreshold)
+		}
+	}
+
+	page, err := s.Client.VectorStores.Search(ctx, s.vectorStoreID, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "vector store search failed")
+		return nil, errors.Wrap(toAPIError(err), "error searching vector store")
+	}
+
+	var results []VectorStoreSearchResult
+	for _, r := range page.Data {
+		var texts []string
+		for _, c := range r.Content {
+			texts = append(texts, c.Text)
+		}
+
+		attributes := map[string]any{}
+		for k, v := range r.Attributes {
+			var value any
+			if err := json.Unmarshal([]byte(v.RawJSON()), &value); err == nil {
+				attributes[k] = value
+			}
+		}
+
+		results = append(results, VectorStoreSearchResult{
+			FileID:     r.FileID,
+			Filename:   r.Filename,
+			Score:      r.Score,
+			Text:       strings.Join(texts, "\n"),
+			Attributes: attributes,
+		})
+	}
+
+	span.SetAttributes(attribute.Int("ai.result_count", len(results)))
+
+	return results, nil
+}
+
+type vectorStoreSearchToolArgs struct {
+	Query string `json:"query"`
+}
+
+// Tool returns a [gai.Tool] that searches the vector store, for retrieval-augmented generation with any [gai.ChatCompleter].
+// The name and description should tell the model what's in the store, for example "search_handbook".
+func (s *VectorStoreSearcher) Tool(name, description string) gai.Tool {
+	return gai.Tool{
+		Name:        name,
+		Description: description,
+		Schema: gai.ToolSchema{
+			Properties: map[string]*gai.Schema{
+				"query": {
+					Type:        gai.SchemaTypeString,
+					Description: "The search query, in natural language.",
+				},
+			},
+		},
+		Execute: func(ctx context.Context, rawArgs json.RawMessage) (string, error) {
+			var args vectorStoreSearchToolArgs
+			if err := json.Unmarshal(rawArgs, &args); err != nil {
+				return "", errors.Wrap(err, "error unmarshaling args")
+			}
+
+			results, err := s.Search(ctx, VectorStoreSearchRequest{Query: args.Query})
+			if err != nil {
+				return "", err
+			}
+
+			if len(results) == 0 {
+				return "No results found.", nil
+			}
+
+			var b strings.Builder
+			for i, r := range results {
+				if i > 0 {
+					b.WriteString("\n\n")
+				}
+				_, _ = fmt.Fprintf(&b, "File: %v (score %.2f)\n%v", r.Filename, r.Score, r.Text)
+			}
+			return b.String(), nil
+		},
+	}
+}
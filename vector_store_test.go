@@ -0,0 +1,161 @@
+package openai_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/gai"
+	"maragu.dev/is"
+
+	openai "maragu.dev/gai-openai"
+)
+
+func TestVectorStoreSearcher_Search(t *testing.T) {
+	t.Run("can search a vector store and use it as a tool", func(t *testing.T) {
+		c := newClient(t)
+
+		file, err := c.UploadFile(t.Context(), strings.NewReader("The secret password for the treehouse is 'marmalade'."), openai.UploadFileOptions{
+			Name:    "treehouse.txt",
+			Purpose: openai.FilePurposeAssistants,
+		})
+		is.NotError(t, err)
+		t.Cleanup(func() { _ = c.DeleteFile(t.Context(), file.ID) })
+
+		store, err := c.CreateVectorStore(t.Context(), openai.CreateVectorStoreOptions{
+			Name:             "gai-openai test",
+			ExpiresAfterDays: 1,
+		})
+		is.NotError(t, err)
+		t.Cleanup(func() { _ = c.DeleteVectorStore(t.Context(), store.ID) })
+
+		err = c.AddVectorStoreFile(t.Context(), store.ID, openai.AddVectorStoreFileOptions{
+			FileID:     file.ID,
+			Attributes: map[string]any{"topic": "treehouse", "year": 2025},
+		})
+		is.NotError(t, err)
+
+		store, err = c.WaitForVectorStore(t.Context(), store.ID, openai.WaitForVectorStoreOptions{PollInterval: 100 * time.Millisecond})
+		is.NotError(t, err)
+		is.Equal(t, 1, store.FileCounts.Completed)
+
+		s := c.NewVectorStoreSearcher(openai.NewVectorStoreSearcherOptions{VectorStoreID: store.ID})
+
+		results, err := s.Search(t.Context(), openai.VectorStoreSearchRequest{
+			Query:  "What is the treehouse password?",
+			Filter: gai.Ptr(openai.FilterAnd(openai.FilterEq("topic", "treehouse"), openai.FilterGte("year", 2025))),
+		})
+		is.NotError(t, err)
+		is.True(t, len(results) > 0, "should have results")
+		is.Equal(t, "treehouse.txt", results[0].Filename)
+		requireContainsAll(t, results[0].Text, "marmalade")
+		is.Equal(t, "treehouse", results[0].Attributes["topic"].(string))
+
+		results, err = s.Search(t.Context(), openai.VectorStoreSearchRequest{
+			Query:  "What is the treehouse password?",
+			Filter: gai.Ptr(openai.FilterEq("topic", "spaceship")),
+		})
+		is.NotError(t, err)
+		is.Equal(t, 0, len(results))
+
+		tool := s.Tool("search_treehouse_docs", "Search documents about the treehouse.")
+		output, err := tool.Execute(t.Context(), json.RawMessage(`{"query":"treehouse password"}`))
+		is.NotError(t, err)
+		requireContainsAll(t, output, "treehouse.txt", "marmalade")
+	})
+
+	t.Run("sends filters and ranking options", func(t *testing.T) {
+		var body map[string]any
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			is.Equal(t, "/vector_stores/vs_1/search", r.URL.Path)
+			b, err := io.ReadAll(r.Body)
+			is.NotError(t, err)
+			is.NotError(t, json.Unmarshal(b, &body))
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"object":"vector_store.search_results.page","search_query":"q","data":[{"file_id":"file-1","filename":"a.txt","score":0.9,"attributes":{"topic":"a"},"content":[{"type":"text","text":"Hello"}]}],"has_more":false,"next_page":null}`))
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+		searcher := c.NewVectorStoreSearcher(openai.NewVectorStoreSearcherOptions{
+			VectorStoreID:  "vs_1",
+			MaxResults:     3,
+			ScoreThreshold: 0.5,
+		})
+
+		results, err := searcher.Search(t.Context(), openai.VectorStoreSearchRequest{
+			Query:  "q",
+			Filter: gai.Ptr(openai.FilterOr(openai.FilterEq("topic", "a"), openai.FilterLt("year", 2000))),
+		})
+		is.NotError(t, err)
+		is.Equal(t, 1, len(results))
+		is.Equal(t, "Hello", results[0].Text)
+		is.Equal(t, 0.9, results[0].Score)
+
+		is.Equal(t, float64(3), body["max_num_results"].(float64))
+		filters := body["filters"].(map[string]any)
+		is.Equal(t, "or", filters["type"].(string))
+		is.Equal(t, 2, len(filters["filters"].([]any)))
+		rankingOptions := body["ranking_options"].(map[string]any)
+		is.Equal(t, 0.5, rankingOptions["score_threshold"].(float64))
+	})
+
+	t.Run("errors on nested compound filters", func(t *testing.T) {
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: "http://localhost:0", Key: "test"})
+		searcher := c.NewVectorStoreSearcher(openai.NewVectorStoreSearcherOptions{VectorStoreID: "vs_1"})
+
+		_, err := searcher.Search(t.Context(), openai.VectorStoreSearchRequest{
+			Query:  "q",
+			Filter: gai.Ptr(openai.FilterAnd(openai.FilterOr(openai.FilterEq("a", 1)))),
+		})
+		is.True(t, err != nil, "should error")
+	})
+
+	t.Run("errors on empty compound filters", func(t *testing.T) {
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: "http://localhost:0", Key: "test"})
+		searcher := c.NewVectorStoreSearcher(openai.NewVectorStoreSearcherOptions{VectorStoreID: "vs_1"})
+
+		for _, filter := range []openai.VectorStoreFilter{openai.FilterAnd(), openai.FilterOr([]openai.VectorStoreFilter{}...)} {
+			_, err := searcher.Search(t.Context(), openai.VectorStoreSearchRequest{
+				Query:  "q",
+				Filter: &filter,
+			})
+			is.True(t, err != nil, "should error")
+			is.True(t, strings.Contains(err.Error(), "must have at least one filter"), err.Error())
+		}
+	})
+}
+
+func TestClient_WaitForVectorStore(t *testing.T) {
+	t.Run("polls until no files are being ingested", func(t *testing.T) {
+		var requests int
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			status, inProgress, completed := "in_progress", 1, 0
+			if requests == 3 {
+				status, inProgress, completed = "completed", 0, 1
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":          "vs_1",
+				"object":      "vector_store",
+				"status":      status,
+				"file_counts": map[string]int{"in_progress": inProgress, "completed": completed, "total": 1},
+			})
+		}))
+		defer s.Close()
+
+		c := openai.NewClient(openai.NewClientOptions{BaseURL: s.URL, Key: "test"})
+
+		store, err := c.WaitForVectorStore(t.Context(), "vs_1", openai.WaitForVectorStoreOptions{PollInterval: time.Millisecond})
+		is.NotError(t, err)
+		is.Equal(t, 3, requests)
+		is.Equal(t, openai.VectorStoreStatusCompleted, store.Status)
+		is.Equal(t, 1, store.FileCounts.Completed)
+	})
+}